@@ -0,0 +1,70 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package example goutil-httpclient-gen生成的客户端示例
+package example
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+//go:generate go run github.com/ouqiang/goutil/cmd/goutil-httpclient-gen -type UserService
+
+// User 用户
+type User struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIError 服务端返回的错误
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api error %d: %s", e.Code, e.Message)
+}
+
+// UserService 用户服务
+// @Error APIError
+type UserService interface {
+	// GetUser 查询用户
+	// @GET /users/{id}
+	// @Query fields
+	GetUser(ctx context.Context, id int64, fields []string) (*User, error)
+
+	// ListUsers 用户列表
+	// @GET /users
+	// @Query name
+	// @Query since created_after
+	// @Query limit
+	// @Query ids id
+	// @Header traceID X-Trace-Id
+	ListUsers(ctx context.Context, name string, since time.Time, limit *int, ids []int64, traceID string) ([]*User, error)
+
+	// CreateUser 创建用户, 失败时重试
+	// @POST /users
+	// @Body user
+	// @Retry 2
+	// @Idempotent
+	CreateUser(ctx context.Context, user *User) (*User, error)
+
+	// DeleteUser 删除用户
+	// @DELETE /groups/{group}/users/{id}
+	DeleteUser(ctx context.Context, group string, id int64) error
+}
@@ -0,0 +1,132 @@
+// Code generated by goutil-httpclient-gen. DO NOT EDIT.
+
+package example
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ouqiang/goutil/httpclient"
+)
+
+// UserServiceClient UserService的HTTP客户端
+type UserServiceClient struct {
+	baseURL       string
+	req           *httpclient.Request
+	createUserReq *httpclient.Request
+}
+
+var _ UserService = (*UserServiceClient)(nil)
+
+// NewUserServiceClient 创建UserService客户端, baseURL如http://127.0.0.1:8080/api
+// 声明了@Retry或@Idempotent的方法使用Clone得到的Request, 与其他方法共享Transport(连接池)
+func NewUserServiceClient(baseURL string, opt ...httpclient.Option) *UserServiceClient {
+	c := &UserServiceClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		req:     httpclient.NewRequest(opt...),
+	}
+	c.createUserReq = c.req.Clone(httpclient.WithRetryTime(2), httpclient.WithIdempotencyKey())
+
+	return c
+}
+
+// GetUser 查询用户
+func (c *UserServiceClient) GetUser(ctx context.Context, id int64, fields []string) (*User, error) {
+	var out *User
+	reqURL := c.baseURL + "/users/" + url.PathEscape(fmt.Sprint(id))
+	query := make(url.Values)
+	for _, v := range fields {
+		query.Add("fields", v)
+	}
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+	resp, err := c.req.Do(ctx, http.MethodGet, reqURL, nil, nil)
+	if err != nil {
+		return out, err
+	}
+	if err = httpclient.CheckStatus(resp, new(APIError)); err != nil {
+		return out, err
+	}
+	err = resp.DecodeJSON(&out)
+
+	return out, err
+}
+
+// ListUsers 用户列表
+func (c *UserServiceClient) ListUsers(ctx context.Context, name string, since time.Time, limit *int, ids []int64, traceID string) ([]*User, error) {
+	var out []*User
+	reqURL := c.baseURL + "/users"
+	query := make(url.Values)
+	if name != "" {
+		query.Set("name", name)
+	}
+	if !since.IsZero() {
+		query.Set("created_after", since.Format(time.RFC3339))
+	}
+	if limit != nil {
+		query.Set("limit", fmt.Sprint(*limit))
+	}
+	for _, v := range ids {
+		query.Add("id", fmt.Sprint(v))
+	}
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+	header := make(http.Header)
+	if traceID != "" {
+		header.Set("X-Trace-Id", traceID)
+	}
+	resp, err := c.req.Do(ctx, http.MethodGet, reqURL, nil, header)
+	if err != nil {
+		return out, err
+	}
+	if err = httpclient.CheckStatus(resp, new(APIError)); err != nil {
+		return out, err
+	}
+	err = resp.DecodeJSON(&out)
+
+	return out, err
+}
+
+// CreateUser 创建用户, 失败时重试
+func (c *UserServiceClient) CreateUser(ctx context.Context, user *User) (*User, error) {
+	var out *User
+	reqURL := c.baseURL + "/users"
+	header := make(http.Header)
+	header.Set("Content-Type", httpclient.MediaTypeJSON)
+	body, err := json.Marshal(user)
+	if err != nil {
+		return out, err
+	}
+	resp, err := c.createUserReq.Do(ctx, http.MethodPost, reqURL, body, header)
+	if err != nil {
+		return out, err
+	}
+	if err = httpclient.CheckStatus(resp, new(APIError)); err != nil {
+		return out, err
+	}
+	err = resp.DecodeJSON(&out)
+
+	return out, err
+}
+
+// DeleteUser 删除用户
+func (c *UserServiceClient) DeleteUser(ctx context.Context, group string, id int64) error {
+	reqURL := c.baseURL + "/groups/" + url.PathEscape(group) + "/users/" + url.PathEscape(fmt.Sprint(id))
+	resp, err := c.req.Do(ctx, http.MethodDelete, reqURL, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err = httpclient.CheckStatus(resp, new(APIError)); err != nil {
+		return err
+	}
+	_, err = resp.Discard()
+
+	return err
+}
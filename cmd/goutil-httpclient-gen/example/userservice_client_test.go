@@ -0,0 +1,116 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package example
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ouqiang/goutil/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserServiceClient(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", httpclient.MediaTypeJSON)
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/api/users/1":
+			require.Equal(t, []string{"id", "name"}, req.URL.Query()["fields"])
+			_, _ = io.WriteString(rw, `{"id":1,"name":"golang"}`)
+		case req.Method == http.MethodGet && req.URL.Path == "/api/users/2":
+			// 没有query时不添加"?"
+			require.Equal(t, "/api/users/2", req.RequestURI)
+			rw.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(rw, `{"code":404,"message":"user not found"}`)
+		case req.Method == http.MethodGet && req.URL.Path == "/api/users" && req.URL.RawQuery == "":
+			// 零值和nil参数不发送
+			require.Equal(t, "/api/users", req.RequestURI)
+			require.Empty(t, req.Header.Values("X-Trace-Id"))
+			_, _ = io.WriteString(rw, `[]`)
+		case req.Method == http.MethodGet && req.URL.Path == "/api/users":
+			require.Equal(t, "go", req.URL.Query().Get("name"))
+			require.Equal(t, "2020-01-02T03:04:05Z", req.URL.Query().Get("created_after"))
+			require.Equal(t, "0", req.URL.Query().Get("limit"))
+			require.Equal(t, []string{"1", "2"}, req.URL.Query()["id"])
+			require.Equal(t, "trace-1", req.Header.Get("X-Trace-Id"))
+			_, _ = io.WriteString(rw, `[{"id":1},{"id":2}]`)
+		case req.Method == http.MethodPost && req.URL.Path == "/api/users":
+			require.Equal(t, httpclient.MediaTypeJSON, req.Header.Get("Content-Type"))
+			mu.Lock()
+			keys = append(keys, req.Header.Get(httpclient.IdempotencyKeyHeader))
+			attempts := len(keys)
+			mu.Unlock()
+			if attempts < 3 {
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			user := &User{}
+			require.NoError(t, json.NewDecoder(req.Body).Decode(user))
+			user.ID = 3
+			_ = json.NewEncoder(rw).Encode(user)
+		case req.Method == http.MethodDelete && req.URL.EscapedPath() == "/api/groups/a%2Fb/users/1":
+			rw.WriteHeader(http.StatusNoContent)
+		default:
+			rw.Header().Set("Content-Type", "text/plain")
+			rw.WriteHeader(http.StatusBadGateway)
+			_, _ = io.WriteString(rw, "bad gateway")
+		}
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	var svc UserService = NewUserServiceClient(s.URL + "/api/")
+	ctx := context.Background()
+
+	user, err := svc.GetUser(ctx, 1, []string{"id", "name"})
+	require.NoError(t, err)
+	require.Equal(t, &User{ID: 1, Name: "golang"}, user)
+
+	_, err = svc.GetUser(ctx, 2, nil)
+	require.Equal(t, &APIError{Code: 404, Message: "user not found"}, err)
+
+	limit := 0
+	users, err := svc.ListUsers(ctx, "go", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), &limit, []int64{1, 2}, "trace-1")
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+
+	users, err = svc.ListUsers(ctx, "", time.Time{}, nil, nil, "")
+	require.NoError(t, err)
+	require.Empty(t, users)
+
+	user, err = svc.CreateUser(ctx, &User{Name: "gopher"})
+	require.NoError(t, err)
+	require.Equal(t, &User{ID: 3, Name: "gopher"}, user)
+	require.Len(t, keys, 3)
+	require.NotEmpty(t, keys[0])
+	require.Equal(t, keys[0], keys[1])
+	require.Equal(t, keys[0], keys[2])
+
+	require.NoError(t, svc.DeleteUser(ctx, "a/b", 1))
+	err = svc.DeleteUser(ctx, "c", 1)
+	statusErr, ok := err.(*httpclient.StatusError)
+	require.True(t, ok)
+	require.Equal(t, http.StatusBadGateway, statusErr.StatusCode)
+}
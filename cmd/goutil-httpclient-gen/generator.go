@@ -0,0 +1,653 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const httpclientImportPath = "github.com/ouqiang/goutil/httpclient"
+
+var (
+	httpMethods = map[string]string{
+		"GET":     "http.MethodGet",
+		"HEAD":    "http.MethodHead",
+		"POST":    "http.MethodPost",
+		"PUT":     "http.MethodPut",
+		"PATCH":   "http.MethodPatch",
+		"DELETE":  "http.MethodDelete",
+		"OPTIONS": "http.MethodOptions",
+	}
+	pathParamRegexp = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	// 生成代码使用的变量名和包名, 参数不能与之重名
+	reservedNames = map[string]bool{
+		"c": true, "reqURL": true, "query": true, "header": true,
+		"body": true, "resp": true, "err": true, "out": true, "v": true,
+		"context": true, "fmt": true, "http": true, "httpclient": true,
+		"json": true, "strings": true, "time": true, "url": true,
+	}
+)
+
+type param struct {
+	Name string
+	Type string
+}
+
+type binding struct {
+	Key   string
+	Param param
+}
+
+type method struct {
+	Name       string
+	Doc        []string
+	HTTPMethod string
+	Path       string
+	Ctx        string
+	Params     []param
+	Result     string
+	Query      []binding
+	Headers    []binding
+	Body       *param
+	Retry      int
+	Idempotent bool
+}
+
+type service struct {
+	Name      string
+	ErrorType string
+	Methods   []*method
+}
+
+type generator struct {
+	fset    *token.FileSet
+	pkg     string
+	imports map[string]string
+	buf     bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// generate 解析dir中的Go文件, 为types中的interface生成客户端代码
+func generate(dir string, types []string) ([]byte, error) {
+	g := &generator{
+		fset:    token.NewFileSet(),
+		imports: make(map[string]string),
+	}
+	pkgs, err := parser.ParseDir(g.fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+	var files []*ast.File
+	for name, pkg := range pkgs {
+		g.pkg = name
+		for _, file := range pkg.Files {
+			if !isGenerated(file) {
+				files = append(files, file)
+			}
+		}
+	}
+
+	var services []*service
+	for _, name := range types {
+		svc, err := g.parseService(files, strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, svc)
+	}
+	// 参数类型引用的包名
+	for _, svc := range services {
+		for _, m := range svc.Methods {
+			for _, p := range m.Params {
+				for _, name := range g.imports {
+					if p.Name == name {
+						return nil, fmt.Errorf("%s.%s: parameter name %s conflicts with imported package", svc.Name, m.Name, p.Name)
+					}
+				}
+			}
+		}
+	}
+	g.imports["net/http"] = "http"
+	g.imports["strings"] = "strings"
+	g.imports[httpclientImportPath] = "httpclient"
+	for _, svc := range services {
+		g.generateService(svc)
+	}
+
+	body := g.buf.Bytes()
+	g.buf = bytes.Buffer{}
+	g.printf("// Code generated by goutil-httpclient-gen. DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", g.pkg)
+	g.printf("import (\n")
+	paths := make([]string, 0, len(g.imports))
+	for p := range g.imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	// 标准库在前, 第三方包在后
+	sort.SliceStable(paths, func(i, j int) bool {
+		return isStdlib(paths[i]) && !isStdlib(paths[j])
+	})
+	for i, p := range paths {
+		if i > 0 && isStdlib(paths[i-1]) && !isStdlib(p) {
+			g.printf("\n")
+		}
+		if name := g.imports[p]; name != path.Base(p) {
+			g.printf("%s %q\n", name, p)
+		} else {
+			g.printf("%q\n", p)
+		}
+	}
+	g.printf(")\n")
+	g.buf.Write(body)
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %s", err)
+	}
+
+	return src, nil
+}
+
+func isStdlib(importPath string) bool {
+	return !strings.Contains(strings.SplitN(importPath, "/", 2)[0], ".")
+}
+
+// isGenerated 跳过生成的文件, 避免解析上次生成的结果
+func isGenerated(file *ast.File) bool {
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Text, "// Code generated ") && strings.HasSuffix(c.Text, " DO NOT EDIT.") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (g *generator) parseService(files []*ast.File, name string) (*service, error) {
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.Name.Name != name {
+					continue
+				}
+				it, ok := ts.Type.(*ast.InterfaceType)
+				if !ok {
+					return nil, fmt.Errorf("%s is not an interface", name)
+				}
+				doc := ts.Doc
+				if doc == nil {
+					doc = gen.Doc
+				}
+				return g.parseInterface(file, name, doc, it)
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("interface %s not found", name)
+}
+
+func (g *generator) parseInterface(file *ast.File, name string, doc *ast.CommentGroup, it *ast.InterfaceType) (*service, error) {
+	svc := &service{Name: name}
+	_, annotations := splitDoc(doc)
+	for _, a := range annotations {
+		switch a[0] {
+		case "@Error":
+			if len(a) != 2 {
+				return nil, fmt.Errorf("%s: usage: @Error Type", name)
+			}
+			svc.ErrorType = a[1]
+		default:
+			return nil, fmt.Errorf("%s: unknown annotation %s", name, a[0])
+		}
+	}
+	for _, field := range it.Methods.List {
+		ft, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) != 1 {
+			return nil, fmt.Errorf("%s: embedded interfaces are not supported", name)
+		}
+		m, err := g.parseMethod(file, field.Names[0].Name, field.Doc, ft)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %s", name, field.Names[0].Name, err)
+		}
+		svc.Methods = append(svc.Methods, m)
+	}
+
+	return svc, nil
+}
+
+func (g *generator) parseMethod(file *ast.File, name string, doc *ast.CommentGroup, ft *ast.FuncType) (*method, error) {
+	m := &method{Name: name}
+	var annotations [][]string
+	m.Doc, annotations = splitDoc(doc)
+
+	params := make(map[string]param)
+	for i, field := range ft.Params.List {
+		if len(field.Names) == 0 {
+			return nil, fmt.Errorf("parameters must be named")
+		}
+		if _, ok := field.Type.(*ast.Ellipsis); ok {
+			return nil, fmt.Errorf("variadic parameters are not supported")
+		}
+		typ := g.typeString(file, field.Type)
+		for _, ident := range field.Names {
+			if i == 0 && m.Ctx == "" {
+				if typ != "context.Context" || ident.Name == "_" {
+					return nil, fmt.Errorf("first parameter must be a named context.Context")
+				}
+				m.Ctx = ident.Name
+				continue
+			}
+			if reservedNames[ident.Name] || ident.Name == "_" {
+				return nil, fmt.Errorf("parameter name %s conflicts with generated code", ident.Name)
+			}
+			p := param{Name: ident.Name, Type: typ}
+			m.Params = append(m.Params, p)
+			params[p.Name] = p
+		}
+	}
+	if m.Ctx == "" {
+		return nil, fmt.Errorf("first parameter must be a named context.Context")
+	}
+	var results []ast.Expr
+	if ft.Results != nil {
+		for _, field := range ft.Results.List {
+			n := len(field.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				results = append(results, field.Type)
+			}
+		}
+	}
+	if len(results) == 0 || len(results) > 2 || g.typeString(file, results[len(results)-1]) != "error" {
+		return nil, fmt.Errorf("results must be error or (T, error)")
+	}
+	if len(results) == 2 {
+		m.Result = g.typeString(file, results[0])
+	}
+
+	used := make(map[string]bool)
+	use := func(name string) (param, error) {
+		p, ok := params[name]
+		if !ok {
+			return p, fmt.Errorf("unknown parameter %s", name)
+		}
+		if used[name] {
+			return p, fmt.Errorf("parameter %s is bound more than once", name)
+		}
+		used[name] = true
+		return p, nil
+	}
+	for _, a := range annotations {
+		tag := strings.TrimPrefix(a[0], "@")
+		if expr, ok := httpMethods[tag]; ok {
+			if len(a) != 2 || m.HTTPMethod != "" {
+				return nil, fmt.Errorf("usage: @%s path, only one method annotation allowed", tag)
+			}
+			m.HTTPMethod, m.Path = expr, a[1]
+			continue
+		}
+		switch tag {
+		case "Query":
+			if len(a) < 2 || len(a) > 3 {
+				return nil, fmt.Errorf("usage: @Query param [key]")
+			}
+			p, err := use(a[1])
+			if err != nil {
+				return nil, err
+			}
+			key := p.Name
+			if len(a) == 3 {
+				key = a[2]
+			}
+			m.Query = append(m.Query, binding{Key: key, Param: p})
+		case "Header":
+			if len(a) != 3 {
+				return nil, fmt.Errorf("usage: @Header param Header-Name")
+			}
+			p, err := use(a[1])
+			if err != nil {
+				return nil, err
+			}
+			m.Headers = append(m.Headers, binding{Key: a[2], Param: p})
+		case "Body":
+			if len(a) != 2 || m.Body != nil {
+				return nil, fmt.Errorf("usage: @Body param, only one body allowed")
+			}
+			p, err := use(a[1])
+			if err != nil {
+				return nil, err
+			}
+			m.Body = &p
+		case "Retry":
+			n, err := strconv.Atoi(strings.Join(a[1:], ""))
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("usage: @Retry n")
+			}
+			m.Retry = n
+		case "Idempotent":
+			m.Idempotent = true
+		default:
+			return nil, fmt.Errorf("unknown annotation %s", a[0])
+		}
+	}
+	if m.HTTPMethod == "" {
+		return nil, fmt.Errorf("missing method annotation, e.g. @GET /path")
+	}
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(m.Path, -1) {
+		p, err := use(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %s", m.Path, err)
+		}
+		if strings.HasPrefix(p.Type, "*") || strings.HasPrefix(p.Type, "[]") {
+			return nil, fmt.Errorf("path %s: parameter %s must not be a pointer or slice", m.Path, p.Name)
+		}
+	}
+	for _, p := range m.Params {
+		if !used[p.Name] {
+			return nil, fmt.Errorf("parameter %s is not bound, use it in the path or annotate it with @Query, @Header or @Body", p.Name)
+		}
+	}
+
+	return m, nil
+}
+
+// splitDoc 区分注释和@注解
+func splitDoc(doc *ast.CommentGroup) (lines []string, annotations [][]string) {
+	if doc == nil {
+		return nil, nil
+	}
+	for _, c := range doc.List {
+		text := strings.TrimPrefix(c.Text, "//")
+		trimmed := strings.TrimSpace(text)
+		if strings.HasPrefix(trimmed, "@") {
+			annotations = append(annotations, strings.Fields(trimmed))
+			continue
+		}
+		lines = append(lines, "//"+text)
+	}
+
+	return lines, annotations
+}
+
+// typeString 输出类型表达式, 记录引用的包
+func (g *generator) typeString(file *ast.File, expr ast.Expr) string {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := sel.X.(*ast.Ident); ok {
+			if p := importPath(file, ident.Name); p != "" {
+				g.imports[p] = ident.Name
+			}
+		}
+		return false
+	})
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, g.fset, expr)
+
+	return buf.String()
+}
+
+func importPath(file *ast.File, name string) string {
+	for _, imp := range file.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		if imp.Name != nil {
+			if imp.Name.Name == name {
+				return p
+			}
+			continue
+		}
+		base := path.Base(p)
+		// gopkg.in/yaml.v3
+		if i := strings.Index(base, ".v"); i > 0 {
+			base = base[:i]
+		}
+		if strings.TrimPrefix(base, "go-") == name || base == name {
+			return p
+		}
+	}
+
+	return ""
+}
+
+func (g *generator) generateService(svc *service) {
+	client := svc.Name + "Client"
+	g.printf("\n// %s %s的HTTP客户端\n", client, svc.Name)
+	g.printf("type %s struct {\n", client)
+	g.printf("baseURL string\n")
+	g.printf("req *httpclient.Request\n")
+	for _, m := range svc.Methods {
+		if m.hasOwnRequest() {
+			g.printf("%s *httpclient.Request\n", m.requestField())
+		}
+	}
+	g.printf("}\n\n")
+	g.printf("var _ %s = (*%s)(nil)\n\n", svc.Name, client)
+
+	g.printf("// New%s 创建%s客户端, baseURL如http://127.0.0.1:8080/api\n", client, svc.Name)
+	g.printf("// 声明了@Retry或@Idempotent的方法使用Clone得到的Request, 与其他方法共享Transport(连接池)\n")
+	g.printf("func New%s(baseURL string, opt ...httpclient.Option) *%s {\n", client, client)
+	g.printf("c := &%s{\n", client)
+	g.printf("baseURL: strings.TrimSuffix(baseURL, \"/\"),\n")
+	g.printf("req: httpclient.NewRequest(opt...),\n")
+	g.printf("}\n")
+	for _, m := range svc.Methods {
+		if !m.hasOwnRequest() {
+			continue
+		}
+		var opts []string
+		if m.Retry > 0 {
+			opts = append(opts, fmt.Sprintf("httpclient.WithRetryTime(%d)", m.Retry))
+		}
+		if m.Idempotent {
+			opts = append(opts, "httpclient.WithIdempotencyKey()")
+		}
+		g.printf("c.%s = c.req.Clone(%s)\n", m.requestField(), strings.Join(opts, ", "))
+	}
+	g.printf("\nreturn c\n}\n")
+
+	for _, m := range svc.Methods {
+		g.generateMethod(client, svc.ErrorType, m)
+	}
+}
+
+func (m *method) hasOwnRequest() bool {
+	return m.Retry > 0 || m.Idempotent
+}
+
+func (m *method) requestField() string {
+	r := []rune(m.Name)
+	r[0] = unicode.ToLower(r[0])
+
+	return string(r) + "Req"
+}
+
+func (g *generator) generateMethod(client, errorType string, m *method) {
+	var params []string
+	params = append(params, m.Ctx+" context.Context")
+	for _, p := range m.Params {
+		params = append(params, p.Name+" "+p.Type)
+	}
+	results := "error"
+	ret := "err"
+	if m.Result != "" {
+		results = "(" + m.Result + ", error)"
+		ret = "out, err"
+	}
+
+	g.printf("\n")
+	if len(m.Doc) == 0 {
+		g.printf("// %s %s %s\n", m.Name, strings.TrimPrefix(m.HTTPMethod, "http.Method"), m.Path)
+	}
+	for _, line := range m.Doc {
+		g.printf("%s\n", line)
+	}
+	g.printf("func (c *%s) %s(%s) %s {\n", client, m.Name, strings.Join(params, ", "), results)
+	if m.Result != "" {
+		g.printf("var out %s\n", m.Result)
+	}
+	g.printf("reqURL := c.baseURL + %s\n", g.pathExpr(m))
+	if len(m.Query) > 0 {
+		g.imports["net/url"] = "url"
+		g.printf("query := make(url.Values)\n")
+		for _, q := range m.Query {
+			g.bindValue("query", q)
+		}
+		sep := "?"
+		if strings.Contains(m.Path, "?") {
+			sep = "&"
+		}
+		g.printf("if len(query) > 0 {\nreqURL += %q + query.Encode()\n}\n", sep)
+	}
+	header := "nil"
+	if len(m.Headers) > 0 || m.Body != nil {
+		header = "header"
+		g.printf("header := make(http.Header)\n")
+		for _, h := range m.Headers {
+			g.bindValue("header", h)
+		}
+	}
+	data := "nil"
+	if m.Body != nil {
+		data = "body"
+		g.imports["encoding/json"] = "json"
+		g.printf("header.Set(\"Content-Type\", httpclient.MediaTypeJSON)\n")
+		g.printf("body, err := json.Marshal(%s)\n", m.Body.Name)
+		g.printf("if err != nil {\nreturn %s\n}\n", ret)
+	}
+	req := "c.req"
+	if m.hasOwnRequest() {
+		req = "c." + m.requestField()
+	}
+	g.printf("resp, err := %s.Do(%s, %s, reqURL, %s, %s)\n", req, m.Ctx, m.HTTPMethod, data, header)
+	g.printf("if err != nil {\nreturn %s\n}\n", ret)
+	target := "nil"
+	if errorType != "" {
+		target = "new(" + errorType + ")"
+	}
+	g.printf("if err = httpclient.CheckStatus(resp, %s); err != nil {\nreturn %s\n}\n", target, ret)
+	if m.Result != "" {
+		g.printf("err = resp.DecodeJSON(&out)\n\nreturn out, err\n")
+	} else {
+		g.printf("_, err = resp.Discard()\n\nreturn err\n")
+	}
+	g.printf("}\n")
+}
+
+// pathExpr 将{name}替换为转义后的参数
+func (g *generator) pathExpr(m *method) string {
+	var parts []string
+	last := 0
+	for _, loc := range pathParamRegexp.FindAllStringSubmatchIndex(m.Path, -1) {
+		if loc[0] > last {
+			parts = append(parts, strconv.Quote(m.Path[last:loc[0]]))
+		}
+		g.imports["net/url"] = "url"
+		name := m.Path[loc[2]:loc[3]]
+		for _, p := range m.Params {
+			if p.Name == name {
+				parts = append(parts, "url.PathEscape("+g.stringExpr(p)+")")
+			}
+		}
+		last = loc[1]
+	}
+	if last < len(m.Path) || len(parts) == 0 {
+		parts = append(parts, strconv.Quote(m.Path[last:]))
+	}
+
+	return strings.Join(parts, " + ")
+}
+
+// bindValue 参数写入query或header, 指针为nil、已知类型为零值时不发送, 切片逐个添加
+func (g *generator) bindValue(target string, b binding) {
+	p := b.Param
+	switch {
+	case strings.HasPrefix(p.Type, "[]"):
+		g.printf("for _, v := range %s {\n%s.Add(%q, %s)\n}\n", p.Name, target, b.Key, g.stringExpr(param{Name: "v", Type: p.Type[2:]}))
+	case strings.HasPrefix(p.Type, "*"):
+		g.printf("if %s != nil {\n%s.Set(%q, %s)\n}\n", p.Name, target, b.Key, g.stringExpr(param{Name: "*" + p.Name, Type: p.Type[1:]}))
+	default:
+		set := fmt.Sprintf("%s.Set(%q, %s)\n", target, b.Key, g.stringExpr(p))
+		if cond := nonZeroExpr(p); cond != "" {
+			g.printf("if %s {\n%s}\n", cond, set)
+		} else {
+			g.printf("%s", set)
+		}
+	}
+}
+
+// nonZeroExpr 参数不为零值的条件, 未知类型返回空
+func nonZeroExpr(p param) string {
+	switch p.Type {
+	case "string":
+		return p.Name + ` != ""`
+	case "bool":
+		return p.Name
+	case "time.Time":
+		return "!" + p.Name + ".IsZero()"
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64":
+		return p.Name + " != 0"
+	}
+
+	return ""
+}
+
+// stringExpr 参数转换为string
+func (g *generator) stringExpr(p param) string {
+	switch p.Type {
+	case "string":
+		return p.Name
+	case "time.Time":
+		if strings.HasPrefix(p.Name, "*") {
+			return "(" + p.Name + ").Format(time.RFC3339)"
+		}
+		return p.Name + ".Format(time.RFC3339)"
+	}
+	g.imports["fmt"] = "fmt"
+
+	return "fmt.Sprint(" + p.Name + ")"
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// 生成结果与提交的example/userservice_client.go一致, 修改生成器后执行go generate ./example更新
+func TestGenerate_Golden(t *testing.T) {
+	src, err := generate("example", []string{"UserService"})
+	require.NoError(t, err)
+	golden, err := ioutil.ReadFile(filepath.Join("example", "userservice_client.go"))
+	require.NoError(t, err)
+	require.Equal(t, string(golden), string(src))
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		err    string
+	}{
+		{
+			name:   "not found",
+			source: `type Other interface{}`,
+			err:    "interface Service not found",
+		},
+		{
+			name: "missing method annotation",
+			source: `type Service interface {
+	Get(ctx context.Context) error
+}`,
+			err: "Service.Get: missing method annotation, e.g. @GET /path",
+		},
+		{
+			name: "missing context",
+			source: `type Service interface {
+	// @GET /items
+	Get(id int) error
+}`,
+			err: "Service.Get: first parameter must be a named context.Context",
+		},
+		{
+			name: "unbound parameter",
+			source: `type Service interface {
+	// @GET /items
+	Get(ctx context.Context, id int) error
+}`,
+			err: "Service.Get: parameter id is not bound, use it in the path or annotate it with @Query, @Header or @Body",
+		},
+		{
+			name: "unknown path parameter",
+			source: `type Service interface {
+	// @GET /items/{id}
+	Get(ctx context.Context) error
+}`,
+			err: "Service.Get: path /items/{id}: unknown parameter id",
+		},
+		{
+			name: "reserved name",
+			source: `type Service interface {
+	// @POST /items
+	// @Body body
+	Create(ctx context.Context, body string) error
+}`,
+			err: "Service.Create: parameter name body conflicts with generated code",
+		},
+		{
+			name: "pointer path parameter",
+			source: `type Service interface {
+	// @GET /items/{id}
+	Get(ctx context.Context, id *int) error
+}`,
+			err: "Service.Get: path /items/{id}: parameter id must not be a pointer or slice",
+		},
+		{
+			name: "package name",
+			source: `type Service interface {
+	// @GET /items
+	// @Query url
+	Get(ctx context.Context, url string) error
+}`,
+			err: "Service.Get: parameter name url conflicts with generated code",
+		},
+		{
+			name: "imported package name",
+			source: `type Service interface {
+	// @GET /items
+	// @Query since
+	// @Query sort
+	Get(ctx context.Context, since sort.IntSlice, sort string) error
+}`,
+			err: "Service.Get: parameter name sort conflicts with imported package",
+		},
+		{
+			name: "bad results",
+			source: `type Service interface {
+	// @GET /items
+	Get(ctx context.Context) string
+}`,
+			err: "Service.Get: results must be error or (T, error)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "httpclient-gen")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			source := "package svc\n\nimport (\n\t\"context\"\n\t\"sort\"\n)\n\nvar _ context.Context\nvar _ sort.IntSlice\n\n" + tt.source + "\n"
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "svc.go"), []byte(source), 0644))
+			_, err = generate(dir, []string{"Service"})
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
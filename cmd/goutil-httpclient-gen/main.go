@@ -0,0 +1,95 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// goutil-httpclient-gen 根据带注解的interface生成基于httpclient.Request的客户端
+//
+// 用法:
+//
+//	//go:generate goutil-httpclient-gen -type UserService
+//
+//	// UserService 用户服务
+//	// @Error APIError
+//	type UserService interface {
+//		// GetUser 查询用户
+//		// @GET /users/{id}
+//		// @Query fields
+//		GetUser(ctx context.Context, id int64, fields string) (*User, error)
+//
+//		// CreateUser 创建用户
+//		// @POST /users
+//		// @Body user
+//		// @Retry 2
+//		// @Idempotent
+//		CreateUser(ctx context.Context, user *User) (*User, error)
+//	}
+//
+// 方法注解:
+//
+//	@GET|@HEAD|@POST|@PUT|@PATCH|@DELETE|@OPTIONS path  请求方法和路径, {name}替换为同名参数
+//	@Query param [key]                                 参数作为query, key默认为参数名
+//	@Header param Header-Name                          参数作为header
+//	@Body param                                        参数编码为JSON body
+//	@Retry n                                           重试次数
+//	@Idempotent                                        非幂等方法自动添加Idempotency-Key, 允许重试
+//
+// query和header参数为零值、nil指针时不发送, 需要发送零值时使用指针类型, 切片的每个元素分别添加
+//
+// interface注解:
+//
+//	@Error T  非2xx响应的body解码为*T返回, *T需实现error, 解码失败时返回*httpclient.StatusError
+//
+// 方法第一个参数必须为context.Context, 返回值为error或(T, error), 2xx响应的body以JSON解码为T
+// 声明了@Retry或@Idempotent的方法通过Request.Clone派生Request, 所有方法共享同一个Transport
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of interface names; required")
+	output := flag.String("output", "", "output file name; default <type>_client.go")
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if args := flag.Args(); len(args) > 0 {
+		dir = args[0]
+	}
+	types := strings.Split(*typeNames, ",")
+	src, err := generate(dir, types)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "goutil-httpclient-gen: %s\n", err)
+		os.Exit(1)
+	}
+	filename := *output
+	if filename == "" {
+		filename = strings.ToLower(types[0]) + "_client.go"
+	}
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(dir, filename)
+	}
+	if err = ioutil.WriteFile(filename, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "goutil-httpclient-gen: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,262 @@
+// Code generated by goutil-openapi-gen. DO NOT EDIT.
+
+// Package example Petstore 1.0.0
+package example
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ouqiang/goutil/httpclient"
+)
+
+// Error 由OpenAPI文档生成
+type Error struct {
+	Code    int32  `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewPet 由OpenAPI文档生成
+type NewPet struct {
+	Name   string     `json:"name"`
+	Status *PetStatus `json:"status,omitempty"`
+	Tag    *string    `json:"tag,omitempty"`
+}
+
+// Pet 宠物
+type Pet struct {
+	Attributes map[string]string `json:"attributes,omitempty"`
+	CreatedAt  *time.Time        `json:"created_at,omitempty"`
+	ID         int64             `json:"id"`
+	Name       string            `json:"name"`
+	Status     *PetStatus        `json:"status,omitempty"`
+	Tag        *string           `json:"tag,omitempty"`
+}
+
+// PetList 由OpenAPI文档生成
+type PetList struct {
+	Data       []*Pet  `json:"data,omitempty"`
+	NextCursor *string `json:"next_cursor,omitempty"`
+}
+
+// PetStatus 宠物状态
+type PetStatus string
+
+// PetStatus可选值
+const (
+	PetStatusAvailable PetStatus = "available"
+	PetStatusPending   PetStatus = "pending"
+	PetStatusSold      PetStatus = "sold"
+)
+
+// Valid 是否为定义的可选值
+func (v PetStatus) Valid() bool {
+	switch v {
+	case PetStatusAvailable, PetStatusPending, PetStatusSold:
+		return true
+	}
+
+	return false
+}
+
+// DefaultBaseURL servers中的第一个地址
+const DefaultBaseURL = "https://petstore.example.com/v1"
+
+// Client Petstore客户端
+type Client struct {
+	baseURL string
+	req     *httpclient.Request
+}
+
+// NewClient 创建客户端, baseURL为空时使用DefaultBaseURL, 认证使用生成的With*选项
+func NewClient(baseURL string, opt ...httpclient.Option) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		req:     httpclient.NewRequest(opt...),
+	}
+}
+
+// ResponseError 非2xx响应, Model为按文档定义解码的错误body, 未定义或解码失败时为nil
+type ResponseError struct {
+	*httpclient.StatusError
+	Model interface{}
+}
+
+func checkStatus(resp *httpclient.Response, model interface{}) error {
+	err := httpclient.CheckStatus(resp, nil)
+	statusErr, ok := err.(*httpclient.StatusError)
+	if !ok {
+		return err
+	}
+	respErr := &ResponseError{StatusError: statusErr}
+	if model != nil && json.Unmarshal(statusErr.Body, model) == nil {
+		respErr.Model = model
+	}
+
+	return respErr
+}
+
+// WithAPIKey securitySchemes.apiKey, API key在header的X-API-Key中
+func WithAPIKey(key string) httpclient.Option {
+	return httpclient.WithAPIKey(httpclient.APIKeyInHeader, "X-API-Key", key)
+}
+
+// WithBearerAuth securitySchemes.bearerAuth, 使用Bearer token认证
+func WithBearerAuth(token string) httpclient.Option {
+	return httpclient.WithBearerToken(token)
+}
+
+// ListPetsParams ListPets的query、header参数
+type ListPetsParams struct {
+	Limit      *int32
+	Status     *PetStatus
+	Tags       []string
+	Cursor     *string
+	XRequestID *string
+}
+
+// ListPets 查询宠物列表
+func (c *Client) ListPets(ctx context.Context, params *ListPetsParams) (*PetList, error) {
+	var out *PetList
+	reqURL := c.baseURL + "/pets"
+	query := make(url.Values)
+	header := make(http.Header)
+	if params != nil {
+		if params.Limit != nil {
+			query.Add("limit", fmt.Sprint(*params.Limit))
+		}
+		if params.Status != nil {
+			query.Add("status", string(*params.Status))
+		}
+		for _, v := range params.Tags {
+			query.Add("tags", v)
+		}
+		if params.Cursor != nil {
+			query.Add("cursor", *params.Cursor)
+		}
+		if params.XRequestID != nil {
+			header.Add("X-Request-ID", *params.XRequestID)
+		}
+	}
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+	resp, err := c.req.Do(ctx, http.MethodGet, reqURL, nil, header)
+	if err != nil {
+		return out, err
+	}
+	if err = checkStatus(resp, new(Error)); err != nil {
+		return out, err
+	}
+	err = resp.DecodeJSON(&out)
+
+	return out, err
+}
+
+// ListPetsPages 分页遍历ListPets, 每项可Decode为*Pet
+func (c *Client) ListPetsPages(params *ListPetsParams) *httpclient.Paginator {
+	reqURL := c.baseURL + "/pets"
+	query := make(url.Values)
+	header := make(http.Header)
+	if params != nil {
+		if params.Limit != nil {
+			query.Add("limit", fmt.Sprint(*params.Limit))
+		}
+		if params.Status != nil {
+			query.Add("status", string(*params.Status))
+		}
+		for _, v := range params.Tags {
+			query.Add("tags", v)
+		}
+		if params.Cursor != nil {
+			query.Add("cursor", *params.Cursor)
+		}
+		if params.XRequestID != nil {
+			header.Add("X-Request-ID", *params.XRequestID)
+		}
+	}
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	return c.req.Paginate(reqURL, header, httpclient.Cursor("/next_cursor", "cursor"), httpclient.WithItemsPointer("/data"))
+}
+
+// CreatePet 创建宠物
+func (c *Client) CreatePet(ctx context.Context, body *NewPet) (*Pet, error) {
+	var out *Pet
+	reqURL := c.baseURL + "/pets"
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	data, err := json.Marshal(body)
+	if err != nil {
+		return out, err
+	}
+	resp, err := c.req.Do(ctx, http.MethodPost, reqURL, data, header)
+	if err != nil {
+		return out, err
+	}
+	if err = checkStatus(resp, new(Error)); err != nil {
+		return out, err
+	}
+	err = resp.DecodeJSON(&out)
+
+	return out, err
+}
+
+// GetPet 查询宠物
+func (c *Client) GetPet(ctx context.Context, petID int64) (*Pet, error) {
+	var out *Pet
+	reqURL := c.baseURL + "/pets/" + url.PathEscape(fmt.Sprint(petID))
+	resp, err := c.req.Do(ctx, http.MethodGet, reqURL, nil, nil)
+	if err != nil {
+		return out, err
+	}
+	if err = checkStatus(resp, new(Error)); err != nil {
+		return out, err
+	}
+	err = resp.DecodeJSON(&out)
+
+	return out, err
+}
+
+// DeletePet 删除宠物
+//
+// Deprecated: DELETE /pets/{petId} is deprecated.
+func (c *Client) DeletePet(ctx context.Context, petID int64) error {
+	reqURL := c.baseURL + "/pets/" + url.PathEscape(fmt.Sprint(petID))
+	resp, err := c.req.Do(ctx, http.MethodDelete, reqURL, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err = checkStatus(resp, nil); err != nil {
+		return err
+	}
+	_, err = resp.Discard()
+
+	return err
+}
+
+// GetPetPhoto 下载宠物照片
+func (c *Client) GetPetPhoto(ctx context.Context, petID int64) (*httpclient.Response, error) {
+	var out *httpclient.Response
+	reqURL := c.baseURL + "/pets/" + url.PathEscape(fmt.Sprint(petID)) + "/photo"
+	resp, err := c.req.Do(ctx, http.MethodGet, reqURL, nil, nil)
+	if err != nil {
+		return out, err
+	}
+	if err = checkStatus(resp, nil); err != nil {
+		return out, err
+	}
+
+	return resp, nil
+}
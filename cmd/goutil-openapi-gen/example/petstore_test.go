@@ -0,0 +1,114 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package example
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ouqiang/goutil/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		rw.Header().Set("Content-Type", httpclient.MediaTypeJSON)
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/v1/pets":
+			query := req.URL.Query()
+			require.Equal(t, "2", query.Get("limit"))
+			require.Equal(t, "available", query.Get("status"))
+			require.Equal(t, []string{"a", "b"}, query["tags"])
+			require.Equal(t, "trace-1", req.Header.Get("X-Request-ID"))
+			if query.Get("cursor") == "" {
+				_, _ = io.WriteString(rw, `{"data":[{"id":1,"name":"cat"},{"id":2,"name":"dog"}],"next_cursor":"c2"}`)
+				return
+			}
+			_, _ = io.WriteString(rw, `{"data":[{"id":3,"name":"fish"}]}`)
+		case req.Method == http.MethodPost && req.URL.Path == "/v1/pets":
+			require.Equal(t, httpclient.MediaTypeJSON, req.Header.Get("Content-Type"))
+			pet := &Pet{}
+			require.NoError(t, json.NewDecoder(req.Body).Decode(pet))
+			pet.ID = 4
+			rw.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(rw).Encode(pet)
+		case req.Method == http.MethodGet && req.URL.Path == "/v1/pets/404":
+			rw.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(rw, `{"code":404,"message":"pet not found"}`)
+		case req.Method == http.MethodDelete && req.URL.Path == "/v1/pets/1":
+			rw.WriteHeader(http.StatusNoContent)
+		case req.Method == http.MethodGet && req.URL.Path == "/v1/pets/1/photo":
+			rw.Header().Set("Content-Type", "image/png")
+			_, _ = io.WriteString(rw, "png")
+		default:
+			rw.WriteHeader(http.StatusBadGateway)
+		}
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	client := NewClient(s.URL+"/v1/", WithBearerAuth("token"))
+	ctx := context.Background()
+
+	limit := int32(2)
+	status := PetStatusAvailable
+	requestID := "trace-1"
+	params := &ListPetsParams{Limit: &limit, Status: &status, Tags: []string{"a", "b"}, XRequestID: &requestID}
+	list, err := client.ListPets(ctx, params)
+	require.NoError(t, err)
+	require.Len(t, list.Data, 2)
+	require.Equal(t, "c2", *list.NextCursor)
+
+	pages := client.ListPetsPages(params)
+	var names []string
+	for pages.Next(ctx) {
+		pet := &Pet{}
+		require.NoError(t, pages.Decode(pet))
+		names = append(names, pet.Name)
+	}
+	require.NoError(t, pages.Err())
+	require.Equal(t, []string{"cat", "dog", "fish"}, names)
+
+	pending := PetStatusPending
+	pet, err := client.CreatePet(ctx, &NewPet{Name: "bird", Status: &pending})
+	require.NoError(t, err)
+	require.Equal(t, int64(4), pet.ID)
+	require.Equal(t, "bird", pet.Name)
+	require.True(t, pet.Status.Valid())
+	// 未设置的可选属性不发送
+	require.Nil(t, pet.Tag)
+	require.Nil(t, pet.CreatedAt)
+	require.False(t, PetStatus("lost").Valid())
+
+	_, err = client.GetPet(ctx, 404)
+	require.Error(t, err)
+	respErr, ok := err.(*ResponseError)
+	require.True(t, ok)
+	require.Equal(t, http.StatusNotFound, respErr.StatusCode)
+	require.Equal(t, &Error{Code: 404, Message: "pet not found"}, respErr.Model)
+
+	require.NoError(t, client.DeletePet(ctx, 1))
+
+	resp, err := client.GetPetPhoto(ctx, 1)
+	require.NoError(t, err)
+	photo, err := resp.Bytes()
+	require.NoError(t, err)
+	require.Equal(t, "png", string(photo))
+}
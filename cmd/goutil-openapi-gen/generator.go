@@ -0,0 +1,1072 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const httpclientImportPath = "github.com/ouqiang/goutil/httpclient"
+
+var (
+	// initialisms 按Go命名习惯全部大写
+	initialisms = map[string]bool{
+		"api": true, "cpu": true, "dns": true, "html": true, "http": true, "https": true,
+		"id": true, "ip": true, "json": true, "sql": true, "tls": true, "ttl": true,
+		"uid": true, "uri": true, "url": true, "uuid": true, "xml": true,
+	}
+	// 生成代码使用的变量名, 参数不能与之重名
+	reservedNames = map[string]bool{
+		"c": true, "ctx": true, "params": true, "body": true, "reqURL": true, "query": true,
+		"header": true, "data": true, "resp": true, "err": true, "out": true,
+	}
+	pathParamRegexp = regexp.MustCompile(`\{([^}]+)\}`)
+	// 方法按固定顺序生成
+	methodOrder = []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH"}
+)
+
+type opParam struct {
+	Name        string
+	In          string
+	Field       string
+	Arg         string
+	Type        string
+	Required    bool
+	Description string
+}
+
+type opBody struct {
+	Type        string
+	ContentType string
+	// Kind json、form、raw
+	Kind string
+}
+
+type op struct {
+	Name       string
+	Method     string
+	Path       string
+	Doc        []string
+	PathParams []*opParam
+	Params     []*opParam
+	Body       *opBody
+	Result     string
+	ErrorType  string
+	Pagination *pagination
+	ItemType   string
+}
+
+type generator struct {
+	doc    *document
+	pkg    string
+	client string
+	// imports 生成代码引用的包
+	imports map[string]bool
+	// types 已生成的类型
+	types map[string]bool
+	// stringTypes 底层类型为string的枚举
+	stringTypes map[string]bool
+	models      bytes.Buffer
+	buf         bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// generate 根据OpenAPI文档生成模型和客户端
+func generate(doc *document, pkg, client string) ([]byte, error) {
+	g := &generator{
+		doc:         doc,
+		pkg:         pkg,
+		client:      client,
+		imports:     map[string]bool{"context": true, "encoding/json": true, "net/http": true, "strings": true, httpclientImportPath: true},
+		types:       make(map[string]bool),
+		stringTypes: make(map[string]bool),
+	}
+	// 客户端类型名不能与模型重名
+	g.types[client] = true
+	g.types["ResponseError"] = true
+
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		if err := g.defineSchema(goName(name), doc.Components.Schemas[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %s", name, err)
+		}
+	}
+	ops, err := g.operations()
+	if err != nil {
+		return nil, err
+	}
+	g.generateClient()
+	if err = g.generateSecurity(); err != nil {
+		return nil, err
+	}
+	for _, o := range ops {
+		g.generateOperation(o)
+		if o.Pagination != nil {
+			g.generatePages(o)
+		}
+	}
+
+	return g.output()
+}
+
+func (g *generator) output() ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by goutil-openapi-gen. DO NOT EDIT.\n\n")
+	if g.doc.Info.Title != "" {
+		fmt.Fprintf(&out, "// Package %s %s %s\n", g.pkg, g.doc.Info.Title, g.doc.Info.Version)
+	}
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", g.pkg)
+	var std, third []string
+	for p := range g.imports {
+		if strings.Contains(strings.SplitN(p, "/", 2)[0], ".") {
+			third = append(third, p)
+		} else {
+			std = append(std, p)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(third)
+	for _, p := range std {
+		fmt.Fprintf(&out, "%q\n", p)
+	}
+	if len(std) > 0 && len(third) > 0 {
+		out.WriteString("\n")
+	}
+	for _, p := range third {
+		fmt.Fprintf(&out, "%q\n", p)
+	}
+	out.WriteString(")\n")
+	out.Write(g.models.Bytes())
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %s", err)
+	}
+
+	return src, nil
+}
+
+// defineSchema 生成components.schemas中的类型
+func (g *generator) defineSchema(name string, s *schema) error {
+	switch {
+	case len(s.Enum) > 0:
+		return g.defineEnum(name, s)
+	case isStruct(s):
+		return g.defineStruct(name, s)
+	}
+	if g.types[name] {
+		return fmt.Errorf("duplicate type name %s", name)
+	}
+	g.types[name] = true
+	typ, err := g.goType(s, name)
+	if err != nil {
+		return err
+	}
+	g.writeDoc(&g.models, name, s.Description)
+	fmt.Fprintf(&g.models, "type %s %s\n", name, typ)
+
+	return nil
+}
+
+func isStruct(s *schema) bool {
+	return s.Ref == "" && len(s.Enum) == 0 && (len(s.AllOf) > 0 || len(s.Properties) > 0 && (s.Type == "" || s.Type == "object"))
+}
+
+// goType schema对应的Go类型, 内联的对象和枚举以hint命名
+func (g *generator) goType(s *schema, hint string) (string, error) {
+	if s == nil {
+		return "interface{}", nil
+	}
+	if s.Ref != "" {
+		name, err := refName(s.Ref, "schemas")
+		if err != nil {
+			return "", err
+		}
+		target, ok := g.doc.Components.Schemas[name]
+		if !ok {
+			return "", fmt.Errorf("$ref %s not found", s.Ref)
+		}
+		if isStruct(target) {
+			return "*" + goName(name), nil
+		}
+		return goName(name), nil
+	}
+	if len(s.Enum) > 0 {
+		return hint, g.defineEnum(hint, s)
+	}
+	if isStruct(s) {
+		return "*" + hint, g.defineStruct(hint, s)
+	}
+	if len(s.OneOf) > 0 || len(s.AnyOf) > 0 {
+		return "json.RawMessage", nil
+	}
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = true
+			return "time.Time", nil
+		case "byte":
+			return "[]byte", nil
+		}
+		return "string", nil
+	case "integer":
+		if s.Format == "int32" {
+			return "int32", nil
+		}
+		return "int64", nil
+	case "number":
+		if s.Format == "float" {
+			return "float32", nil
+		}
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		item, err := g.goType(s.Items, hint+"Item")
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object":
+		if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+			value, err := g.goType(s.AdditionalProperties.Schema, hint+"Value")
+			if err != nil {
+				return "", err
+			}
+			return "map[string]" + value, nil
+		}
+		return "map[string]interface{}", nil
+	}
+
+	return "interface{}", nil
+}
+
+func (g *generator) defineEnum(name string, s *schema) error {
+	if g.types[name] {
+		return fmt.Errorf("duplicate type name %s", name)
+	}
+	g.types[name] = true
+	base := "string"
+	if s.Type == "integer" {
+		base = "int64"
+	} else {
+		g.stringTypes[name] = true
+	}
+
+	w := &g.models
+	g.writeDoc(w, name, s.Description)
+	fmt.Fprintf(w, "type %s %s\n\n", name, base)
+	fmt.Fprintf(w, "// %s可选值\nconst (\n", name)
+	var consts []string
+	seen := make(map[string]bool)
+	for _, v := range s.Enum {
+		value := fmt.Sprint(v)
+		constName := name + camel(value)
+		if value == "" {
+			constName = name + "Empty"
+		}
+		for i := 2; seen[constName]; i++ {
+			constName = name + camel(value) + strconv.Itoa(i)
+		}
+		seen[constName] = true
+		consts = append(consts, constName)
+		if base == "string" {
+			fmt.Fprintf(w, "%s %s = %q\n", constName, name, value)
+		} else {
+			fmt.Fprintf(w, "%s %s = %s\n", constName, name, value)
+		}
+	}
+	fmt.Fprintf(w, ")\n\n")
+	fmt.Fprintf(w, "// Valid 是否为定义的可选值\nfunc (v %s) Valid() bool {\nswitch v {\ncase %s:\nreturn true\n}\n\nreturn false\n}\n\n",
+		name, strings.Join(consts, ", "))
+
+	return nil
+}
+
+func (g *generator) defineStruct(name string, s *schema) error {
+	if g.types[name] {
+		return fmt.Errorf("duplicate type name %s", name)
+	}
+	g.types[name] = true
+	properties := make(map[string]*schema)
+	required := make(map[string]bool)
+	if err := g.collectProperties(s, properties, required, 0); err != nil {
+		return err
+	}
+
+	// 字段类型可能生成新的类型, 先生成字段再写入
+	var fields bytes.Buffer
+	for _, prop := range sortedKeys(properties) {
+		ps := properties[prop]
+		field := goName(prop)
+		typ, err := g.goType(ps, name+field)
+		if err != nil {
+			return fmt.Errorf("property %s: %s", prop, err)
+		}
+		tag := prop
+		if !required[prop] {
+			typ = optionalType(typ)
+			tag += ",omitempty"
+		}
+		if ps.Description != "" {
+			g.writeDoc(&fields, field, ps.Description)
+		}
+		fmt.Fprintf(&fields, "%s %s `json:%q`\n", field, typ, tag)
+	}
+	g.writeDoc(&g.models, name, s.Description)
+	fmt.Fprintf(&g.models, "type %s struct {\n%s}\n\n", name, fields.String())
+
+	return nil
+}
+
+// optionalType 可选的参数和属性使用指针区分未设置和零值, 切片、map、指针和interface{}本身可为nil
+func optionalType(typ string) string {
+	if strings.HasPrefix(typ, "[]") || strings.HasPrefix(typ, "map[") || strings.HasPrefix(typ, "*") ||
+		typ == "interface{}" || typ == "json.RawMessage" {
+		return typ
+	}
+
+	return "*" + typ
+}
+
+// collectProperties 合并allOf中的属性
+func (g *generator) collectProperties(s *schema, properties map[string]*schema, required map[string]bool, depth int) error {
+	if depth > 32 {
+		return fmt.Errorf("allOf nested too deep")
+	}
+	if s.Ref != "" {
+		name, err := refName(s.Ref, "schemas")
+		if err != nil {
+			return err
+		}
+		target, ok := g.doc.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("$ref %s not found", s.Ref)
+		}
+		return g.collectProperties(target, properties, required, depth+1)
+	}
+	for _, sub := range s.AllOf {
+		if err := g.collectProperties(sub, properties, required, depth+1); err != nil {
+			return err
+		}
+	}
+	for name, prop := range s.Properties {
+		properties[name] = prop
+	}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+
+	return nil
+}
+
+// writeDoc 输出以名称开头的注释
+func (g *generator) writeDoc(w *bytes.Buffer, name, description string) {
+	lines := strings.Split(strings.TrimSpace(description), "\n")
+	if lines[0] == "" {
+		fmt.Fprintf(w, "\n// %s 由OpenAPI文档生成\n", name)
+		return
+	}
+	fmt.Fprintf(w, "\n// %s %s\n", name, strings.TrimSpace(lines[0]))
+	for _, line := range lines[1:] {
+		fmt.Fprintf(w, "// %s\n", strings.TrimRight(line, " "))
+	}
+}
+
+func (g *generator) operations() ([]*op, error) {
+	var ops []*op
+	names := make(map[string]string)
+	for _, p := range sortedKeys(g.doc.Paths) {
+		item := g.doc.Paths[p]
+		byMethod := map[string]*operation{
+			"GET": item.Get, "PUT": item.Put, "POST": item.Post, "DELETE": item.Delete,
+			"OPTIONS": item.Options, "HEAD": item.Head, "PATCH": item.Patch,
+		}
+		for _, method := range methodOrder {
+			operation := byMethod[method]
+			if operation == nil {
+				continue
+			}
+			o, err := g.parseOperation(method, p, item, operation)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %s", method, p, err)
+			}
+			if prev, ok := names[o.Name]; ok {
+				return nil, fmt.Errorf("%s %s: operation name %s already used by %s", method, p, o.Name, prev)
+			}
+			names[o.Name] = method + " " + p
+			ops = append(ops, o)
+		}
+	}
+
+	return ops, nil
+}
+
+func (g *generator) parseOperation(method, path string, item *pathItem, operation *operation) (*op, error) {
+	o := &op{
+		Method:     "http.Method" + strings.Title(strings.ToLower(method)),
+		Path:       path,
+		Pagination: operation.Pagination,
+	}
+	if operation.OperationID != "" {
+		o.Name = goName(operation.OperationID)
+	} else {
+		o.Name = goName(strings.ToLower(method) + " " + pathParamRegexp.ReplaceAllString(path, "by $1"))
+	}
+	summary := strings.TrimSpace(operation.Summary)
+	if summary == "" {
+		summary = method + " " + path
+	}
+	o.Doc = append(o.Doc, "// "+o.Name+" "+summary)
+	if description := strings.TrimSpace(operation.Description); description != "" {
+		o.Doc = append(o.Doc, "//")
+		for _, line := range strings.Split(description, "\n") {
+			o.Doc = append(o.Doc, strings.TrimRight("// "+line, " "))
+		}
+	}
+	if operation.Deprecated {
+		o.Doc = append(o.Doc, "//", "// Deprecated: "+method+" "+path+" is deprecated.")
+	}
+
+	if err := g.parseParameters(o, item.Parameters, operation.Parameters); err != nil {
+		return nil, err
+	}
+	if err := g.parseRequestBody(o, operation.RequestBody); err != nil {
+		return nil, err
+	}
+	if err := g.parseResponses(o, operation.Responses); err != nil {
+		return nil, err
+	}
+	if o.Pagination != nil {
+		if err := g.checkPagination(o, method, operation); err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
+
+func (g *generator) parseParameters(o *op, common, own []*parameter) error {
+	var params []*parameter
+	index := make(map[string]int)
+	for _, list := range [][]*parameter{common, own} {
+		for _, p := range list {
+			if p.Ref != "" {
+				name, err := refName(p.Ref, "parameters")
+				if err != nil {
+					return err
+				}
+				target, ok := g.doc.Components.Parameters[name]
+				if !ok {
+					return fmt.Errorf("$ref %s not found", p.Ref)
+				}
+				p = target
+			}
+			// 操作中的参数覆盖路径中的同名参数
+			key := p.In + ":" + p.Name
+			if i, ok := index[key]; ok {
+				params[i] = p
+				continue
+			}
+			index[key] = len(params)
+			params = append(params, p)
+		}
+	}
+
+	args := make(map[string]bool)
+	fields := make(map[string]bool)
+	for _, p := range params {
+		param := &opParam{
+			Name:        p.Name,
+			In:          p.In,
+			Required:    p.Required || p.In == "path",
+			Description: p.Description,
+		}
+		typ, err := g.goType(p.Schema, o.Name+goName(p.Name))
+		if err != nil {
+			return fmt.Errorf("parameter %s: %s", p.Name, err)
+		}
+		param.Type = typ
+		switch p.In {
+		case "path":
+			param.Arg = argName(p.Name)
+			for args[param.Arg] {
+				param.Arg += "_"
+			}
+			args[param.Arg] = true
+		case "query", "header", "cookie":
+			param.Field = goName(p.Name)
+			for fields[param.Field] {
+				param.Field += "_"
+			}
+			fields[param.Field] = true
+			if !param.Required {
+				param.Type = optionalType(typ)
+			}
+			o.Params = append(o.Params, param)
+			continue
+		default:
+			return fmt.Errorf("parameter %s: unsupported location %q", p.Name, p.In)
+		}
+		o.PathParams = append(o.PathParams, param)
+	}
+	// 路径参数按在路径中出现的顺序排列
+	var ordered []*opParam
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(o.Path, -1) {
+		found := false
+		for _, p := range o.PathParams {
+			if p.Name == match[1] {
+				ordered = append(ordered, p)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("path parameter %s is not defined", match[1])
+		}
+	}
+	if len(ordered) != len(o.PathParams) {
+		return fmt.Errorf("path parameters do not match the path template")
+	}
+	o.PathParams = ordered
+
+	return nil
+}
+
+func (g *generator) parseRequestBody(o *op, body *requestBody) error {
+	if body == nil {
+		return nil
+	}
+	if body.Ref != "" {
+		name, err := refName(body.Ref, "requestBodies")
+		if err != nil {
+			return err
+		}
+		target, ok := g.doc.Components.RequestBodies[name]
+		if !ok {
+			return fmt.Errorf("$ref %s not found", body.Ref)
+		}
+		body = target
+	}
+	if contentType, media := jsonContent(body.Content); media != nil {
+		typ, err := g.goType(media.Schema, o.Name+"Request")
+		if err != nil {
+			return fmt.Errorf("request body: %s", err)
+		}
+		o.Body = &opBody{Type: typ, ContentType: contentType, Kind: "json"}
+		return nil
+	}
+	if _, ok := body.Content["application/x-www-form-urlencoded"]; ok {
+		g.imports["net/url"] = true
+		o.Body = &opBody{Type: "url.Values", ContentType: "application/x-www-form-urlencoded", Kind: "form"}
+		return nil
+	}
+	for _, contentType := range sortedKeys(body.Content) {
+		g.imports["io"] = true
+		o.Body = &opBody{Type: "io.Reader", ContentType: contentType, Kind: "raw"}
+		break
+	}
+
+	return nil
+}
+
+func (g *generator) parseResponses(o *op, responses map[string]*response) error {
+	codes := sortedKeys(responses)
+	// default放在最后
+	sort.SliceStable(codes, func(i, j int) bool {
+		return codes[i] != "default" && codes[j] == "default"
+	})
+	successFound := false
+	for _, code := range codes {
+		resp := responses[code]
+		if resp.Ref != "" {
+			name, err := refName(resp.Ref, "responses")
+			if err != nil {
+				return err
+			}
+			target, ok := g.doc.Components.Responses[name]
+			if !ok {
+				return fmt.Errorf("$ref %s not found", resp.Ref)
+			}
+			resp = target
+		}
+		success := strings.HasPrefix(code, "2")
+		_, media := jsonContent(resp.Content)
+		switch {
+		case success && !successFound:
+			successFound = true
+			if media != nil {
+				typ, err := g.goType(media.Schema, o.Name+"Response")
+				if err != nil {
+					return fmt.Errorf("response %s: %s", code, err)
+				}
+				o.Result = typ
+			} else if len(resp.Content) > 0 {
+				// 非JSON响应由调用方读取
+				o.Result = "*httpclient.Response"
+			}
+		case !success && o.ErrorType == "" && media != nil:
+			typ, err := g.goType(media.Schema, o.Name+"Error")
+			if err != nil {
+				return fmt.Errorf("response %s: %s", code, err)
+			}
+			o.ErrorType = typ
+		}
+	}
+
+	return nil
+}
+
+func (g *generator) checkPagination(o *op, method string, operation *operation) error {
+	p := o.Pagination
+	if method != "GET" {
+		return fmt.Errorf("x-pagination is only supported for GET")
+	}
+	switch p.Type {
+	case "link":
+	case "cursor":
+		if p.Param == "" || p.Cursor == "" {
+			return fmt.Errorf("x-pagination: cursor requires param and cursor")
+		}
+	case "page":
+		if p.Param == "" {
+			return fmt.Errorf("x-pagination: page requires param")
+		}
+	case "offset":
+		if p.Param == "" || p.PageSize <= 0 {
+			return fmt.Errorf("x-pagination: offset requires param and pageSize")
+		}
+	default:
+		return fmt.Errorf("x-pagination: unknown type %q, expected link, cursor, page or offset", p.Type)
+	}
+	o.ItemType = g.itemType(o.Result, p.Items)
+
+	return nil
+}
+
+// itemType 根据items的JSON pointer推断列表项类型, 仅支持顶层或一级属性
+func (g *generator) itemType(result, pointer string) string {
+	typ := result
+	if field := strings.Trim(pointer, "/"); field != "" {
+		if strings.Contains(field, "/") {
+			return ""
+		}
+		name := strings.TrimPrefix(typ, "*")
+		s := g.doc.Components.Schemas[name]
+		if s == nil {
+			for schemaName, candidate := range g.doc.Components.Schemas {
+				if goName(schemaName) == name {
+					s = candidate
+				}
+			}
+		}
+		if s == nil || s.Properties[field] == nil {
+			return ""
+		}
+		var err error
+		if typ, err = g.goType(s.Properties[field], name+goName(field)); err != nil {
+			return ""
+		}
+	}
+	if !strings.HasPrefix(typ, "[]") {
+		return ""
+	}
+
+	return strings.TrimPrefix(typ, "[]")
+}
+
+func jsonContent(content map[string]*mediaType) (string, *mediaType) {
+	for _, contentType := range sortedKeys(content) {
+		if contentType == "application/json" || strings.HasSuffix(contentType, "+json") {
+			return contentType, content[contentType]
+		}
+	}
+
+	return "", nil
+}
+
+func (g *generator) generateClient() {
+	if len(g.doc.Servers) > 0 {
+		g.printf("\n// DefaultBaseURL servers中的第一个地址\nconst DefaultBaseURL = %q\n", g.doc.Servers[0].URL)
+	} else {
+		g.printf("\n// DefaultBaseURL 文档未定义servers\nconst DefaultBaseURL = \"\"\n")
+	}
+	title := g.doc.Info.Title
+	if title == "" {
+		title = "API"
+	}
+	g.printf("\n// %s %s客户端\ntype %s struct {\nbaseURL string\nreq *httpclient.Request\n}\n", g.client, title, g.client)
+	g.printf("\n// New%s 创建客户端, baseURL为空时使用DefaultBaseURL, 认证使用生成的With*选项\n", g.client)
+	g.printf("func New%s(baseURL string, opt ...httpclient.Option) *%s {\n", g.client, g.client)
+	g.printf("if baseURL == \"\" {\nbaseURL = DefaultBaseURL\n}\n\n")
+	g.printf("return &%s{\nbaseURL: strings.TrimSuffix(baseURL, \"/\"),\nreq: httpclient.NewRequest(opt...),\n}\n}\n", g.client)
+
+	g.printf(`
+// ResponseError 非2xx响应, Model为按文档定义解码的错误body, 未定义或解码失败时为nil
+type ResponseError struct {
+	*httpclient.StatusError
+	Model interface{}
+}
+
+func checkStatus(resp *httpclient.Response, model interface{}) error {
+	err := httpclient.CheckStatus(resp, nil)
+	statusErr, ok := err.(*httpclient.StatusError)
+	if !ok {
+		return err
+	}
+	respErr := &ResponseError{StatusError: statusErr}
+	if model != nil && json.Unmarshal(statusErr.Body, model) == nil {
+		respErr.Model = model
+	}
+
+	return respErr
+}
+`)
+}
+
+// generateSecurity securitySchemes映射为httpclient的认证选项
+func (g *generator) generateSecurity() error {
+	for _, name := range sortedKeys(g.doc.Components.SecuritySchemes) {
+		s := g.doc.Components.SecuritySchemes[name]
+		fn := "With" + goName(name)
+		switch {
+		case s.Type == "http" && strings.EqualFold(s.Scheme, "basic"):
+			g.printf("\n// %s securitySchemes.%s, HTTP Basic认证\n", fn, name)
+			g.printf("func %s(username, password string) httpclient.Option {\nreturn httpclient.WithBasicAuth(username, password)\n}\n", fn)
+		case s.Type == "http" && strings.EqualFold(s.Scheme, "bearer"), s.Type == "oauth2", s.Type == "openIdConnect":
+			g.printf("\n// %s securitySchemes.%s, 使用Bearer token认证\n", fn, name)
+			g.printf("func %s(token string) httpclient.Option {\nreturn httpclient.WithBearerToken(token)\n}\n", fn)
+		case s.Type == "apiKey":
+			location := map[string]string{
+				"header": "httpclient.APIKeyInHeader",
+				"query":  "httpclient.APIKeyInQuery",
+				"cookie": "httpclient.APIKeyInCookie",
+			}[s.In]
+			if location == "" {
+				return fmt.Errorf("security scheme %s: unsupported apiKey location %q", name, s.In)
+			}
+			g.printf("\n// %s securitySchemes.%s, API key在%s的%s中\n", fn, name, s.In, s.Name)
+			g.printf("func %s(key string) httpclient.Option {\nreturn httpclient.WithAPIKey(%s, %q, key)\n}\n", fn, location, s.Name)
+		default:
+			return fmt.Errorf("security scheme %s: unsupported type %q", name, s.Type)
+		}
+	}
+
+	return nil
+}
+
+func (g *generator) generateOperation(o *op) {
+	if len(o.Params) > 0 {
+		g.printf("\n// %sParams %s的query、header参数\ntype %sParams struct {\n", o.Name, o.Name, o.Name)
+		for _, p := range o.Params {
+			if p.Description != "" {
+				g.printf("// %s %s\n", p.Field, strings.TrimSpace(strings.Split(p.Description, "\n")[0]))
+			}
+			g.printf("%s %s\n", p.Field, p.Type)
+		}
+		g.printf("}\n")
+	}
+
+	args := []string{"ctx context.Context"}
+	for _, p := range o.PathParams {
+		args = append(args, p.Arg+" "+p.Type)
+	}
+	if len(o.Params) > 0 {
+		args = append(args, "params *"+o.Name+"Params")
+	}
+	if o.Body != nil {
+		args = append(args, "body "+o.Body.Type)
+	}
+	results := "error"
+	ret := "err"
+	if o.Result != "" {
+		results = "(" + o.Result + ", error)"
+		ret = "out, err"
+	}
+
+	g.printf("\n%s\n", strings.Join(o.Doc, "\n"))
+	g.printf("func (c *%s) %s(%s) %s {\n", g.client, o.Name, strings.Join(args, ", "), results)
+	if o.Result != "" {
+		g.printf("var out %s\n", o.Result)
+	}
+	header := g.buildURL(o)
+	data := "nil"
+	if o.Body != nil {
+		if header == "nil" {
+			header = "header"
+			g.printf("header := make(http.Header)\n")
+		}
+		g.printf("header.Set(\"Content-Type\", %q)\n", o.Body.ContentType)
+		switch o.Body.Kind {
+		case "json":
+			data = "data"
+			g.printf("data, err := json.Marshal(body)\nif err != nil {\nreturn %s\n}\n", ret)
+		case "form":
+			data = "body.Encode()"
+		default:
+			data = "body"
+		}
+	}
+	g.printf("resp, err := c.req.Do(ctx, %s, reqURL, %s, %s)\n", o.Method, data, header)
+	g.printf("if err != nil {\nreturn %s\n}\n", ret)
+	errorModel := "nil"
+	if o.ErrorType != "" {
+		errorModel = "new(" + strings.TrimPrefix(o.ErrorType, "*") + ")"
+	}
+	g.printf("if err = checkStatus(resp, %s); err != nil {\nreturn %s\n}\n", errorModel, ret)
+	switch o.Result {
+	case "":
+		g.printf("_, err = resp.Discard()\n\nreturn err\n")
+	case "*httpclient.Response":
+		g.printf("\nreturn resp, nil\n")
+	default:
+		g.printf("err = resp.DecodeJSON(&out)\n\nreturn out, err\n")
+	}
+	g.printf("}\n")
+}
+
+// generatePages 按x-pagination生成分页迭代方法
+func (g *generator) generatePages(o *op) {
+	p := o.Pagination
+	var strategy string
+	switch p.Type {
+	case "link":
+		strategy = "httpclient.LinkHeader()"
+	case "cursor":
+		strategy = fmt.Sprintf("httpclient.Cursor(%q, %q)", p.Cursor, p.Param)
+	case "page":
+		start := 1
+		if p.Start != nil {
+			start = *p.Start
+		}
+		strategy = fmt.Sprintf("httpclient.PageNumber(%q, %d)", p.Param, start)
+	case "offset":
+		strategy = fmt.Sprintf("httpclient.Offset(%q, %d)", p.Param, p.PageSize)
+	}
+	opts := ""
+	if p.Items != "" {
+		opts = fmt.Sprintf(", httpclient.WithItemsPointer(%q)", p.Items)
+	}
+
+	var args []string
+	for _, param := range o.PathParams {
+		args = append(args, param.Arg+" "+param.Type)
+	}
+	if len(o.Params) > 0 {
+		args = append(args, "params *"+o.Name+"Params")
+	}
+	g.printf("\n// %sPages 分页遍历%s", o.Name, o.Name)
+	if o.ItemType != "" {
+		g.printf(", 每项可Decode为%s", o.ItemType)
+	}
+	g.printf("\nfunc (c *%s) %sPages(%s) *httpclient.Paginator {\n", g.client, o.Name, strings.Join(args, ", "))
+	header := g.buildURL(o)
+	g.printf("\nreturn c.req.Paginate(reqURL, %s, %s%s)\n}\n", header, strategy, opts)
+}
+
+// buildURL 生成构造reqURL和header的代码, 返回header变量名, 没有header参数时为nil
+func (g *generator) buildURL(o *op) string {
+	var parts []string
+	last := 0
+	for i, loc := range pathParamRegexp.FindAllStringSubmatchIndex(o.Path, -1) {
+		if loc[0] > last {
+			parts = append(parts, strconv.Quote(o.Path[last:loc[0]]))
+		}
+		g.imports["net/url"] = true
+		parts = append(parts, "url.PathEscape("+g.stringExpr(o.PathParams[i].Arg, o.PathParams[i].Type)+")")
+		last = loc[1]
+	}
+	if last < len(o.Path) || len(parts) == 0 {
+		parts = append(parts, strconv.Quote(o.Path[last:]))
+	}
+	g.printf("reqURL := c.baseURL + %s\n", strings.Join(parts, " + "))
+	if len(o.Params) == 0 {
+		return "nil"
+	}
+
+	var hasQuery, hasHeader bool
+	for _, p := range o.Params {
+		hasQuery = hasQuery || p.In == "query"
+		hasHeader = hasHeader || p.In != "query"
+	}
+	if hasQuery {
+		g.imports["net/url"] = true
+		g.printf("query := make(url.Values)\n")
+	}
+	header := "nil"
+	if hasHeader {
+		header = "header"
+		g.printf("header := make(http.Header)\n")
+	}
+	g.printf("if params != nil {\n")
+	for _, p := range o.Params {
+		field := "params." + p.Field
+		var set func(value string) string
+		switch p.In {
+		case "query":
+			set = func(value string) string { return fmt.Sprintf("query.Add(%q, %s)", p.Name, value) }
+		case "header":
+			set = func(value string) string { return fmt.Sprintf("header.Add(%q, %s)", p.Name, value) }
+		default:
+			set = func(value string) string {
+				return fmt.Sprintf("header.Add(\"Cookie\", (&http.Cookie{Name: %q, Value: %s}).String())", p.Name, value)
+			}
+		}
+		switch {
+		case strings.HasPrefix(p.Type, "[]"):
+			g.printf("for _, v := range %s {\n%s\n}\n", field, set(g.stringExpr("v", strings.TrimPrefix(p.Type, "[]"))))
+		case strings.HasPrefix(p.Type, "*") && !p.Required:
+			g.printf("if %s != nil {\n%s\n}\n", field, set(g.stringExpr("*"+field, strings.TrimPrefix(p.Type, "*"))))
+		default:
+			g.printf("%s\n", set(g.stringExpr(field, p.Type)))
+		}
+	}
+	g.printf("}\n")
+	if hasQuery {
+		g.printf("if len(query) > 0 {\nreqURL += \"?\" + query.Encode()\n}\n")
+	}
+
+	return header
+}
+
+// stringExpr 参数值转换为string
+func (g *generator) stringExpr(expr, typ string) string {
+	switch {
+	case typ == "string":
+		return expr
+	case g.stringTypes[typ]:
+		return "string(" + expr + ")"
+	case typ == "time.Time":
+		return expr + ".Format(time.RFC3339)"
+	}
+	g.imports["fmt"] = true
+
+	return "fmt.Sprint(" + expr + ")"
+}
+
+// goName 转换为导出的Go标识符, 如pet_id -> PetID
+func goName(s string) string {
+	name := camel(s)
+	if name == "" {
+		return "X"
+	}
+	if r := []rune(name); unicode.IsDigit(r[0]) {
+		name = "X" + name
+	}
+
+	return name
+}
+
+// argName 转换为参数名, 如pet_id -> petID
+func argName(s string) string {
+	words := splitWords(s)
+	if len(words) == 0 {
+		return "arg"
+	}
+	name := strings.ToLower(words[0]) + camel(strings.Join(words[1:], " "))
+	if r := []rune(name); unicode.IsDigit(r[0]) {
+		name = "arg" + name
+	}
+	if token.Lookup(name).IsKeyword() || reservedNames[name] {
+		name += "Param"
+	}
+
+	return name
+}
+
+func camel(s string) string {
+	var b strings.Builder
+	for _, word := range splitWords(s) {
+		if initialisms[strings.ToLower(word)] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		r := []rune(word)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+
+	return b.String()
+}
+
+// splitWords 按非字母数字字符和大小写边界拆分, 如petId、pet_id、HTTPServer
+func splitWords(s string) []string {
+	var words []string
+	var cur []rune
+	runes := []rune(s)
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, string(cur))
+			cur = nil
+		}
+	}
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if i > 0 && unicode.IsUpper(r) && len(cur) > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				flush()
+			}
+		}
+		cur = append(cur, r)
+	}
+	flush()
+
+	return words
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch v := m.(type) {
+	case map[string]*schema:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]*pathItem:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]*response:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]*mediaType:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]*securityScheme:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
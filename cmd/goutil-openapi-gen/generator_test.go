@@ -0,0 +1,137 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// 生成结果与提交的example/petstore.go一致, 修改生成器后执行go generate ./example更新
+func TestGenerate_Golden(t *testing.T) {
+	doc, err := loadDocument(filepath.Join("example", "petstore.yaml"))
+	require.NoError(t, err)
+	src, err := generate(doc, "example", "Client")
+	require.NoError(t, err)
+	golden, err := ioutil.ReadFile(filepath.Join("example", "petstore.go"))
+	require.NoError(t, err)
+	require.Equal(t, string(golden), string(src))
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		err  string
+	}{
+		{
+			name: "not openapi 3",
+			spec: `swagger: "2.0"`,
+			err:  `unsupported openapi version "", only 3.x is supported`,
+		},
+		{
+			name: "undefined path parameter",
+			spec: `openapi: 3.0.0
+paths:
+  /items/{id}:
+    get:
+      responses:
+        '204':
+          description: ok`,
+			err: "GET /items/{id}: path parameter id is not defined",
+		},
+		{
+			name: "missing ref",
+			spec: `openapi: 3.0.0
+paths:
+  /items:
+    get:
+      responses:
+        '200':
+          description: ok
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Item'`,
+			err: "GET /items: response 200: $ref #/components/schemas/Item not found",
+		},
+		{
+			name: "duplicate operation",
+			spec: `openapi: 3.0.0
+paths:
+  /a:
+    get:
+      operationId: list
+      responses: {}
+  /b:
+    get:
+      operationId: list
+      responses: {}`,
+			err: "GET /b: operation name List already used by GET /a",
+		},
+		{
+			name: "bad pagination",
+			spec: `openapi: 3.0.0
+paths:
+  /items:
+    get:
+      x-pagination:
+        type: cursor
+      responses: {}`,
+			err: "GET /items: x-pagination: cursor requires param and cursor",
+		},
+		{
+			name: "unsupported security scheme",
+			spec: `openapi: 3.0.0
+paths: {}
+components:
+  securitySchemes:
+    digest:
+      type: http
+      scheme: digest`,
+			err: `security scheme digest: unsupported type "http"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "openapi-gen")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			filename := filepath.Join(dir, "spec.yaml")
+			require.NoError(t, ioutil.WriteFile(filename, []byte(tt.spec), 0644))
+			doc, err := loadDocument(filename)
+			if err == nil {
+				_, err = generate(doc, "api", "Client")
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestGoName(t *testing.T) {
+	require.Equal(t, "PetID", goName("petId"))
+	require.Equal(t, "PetID", goName("pet_id"))
+	require.Equal(t, "XRequestID", goName("X-Request-ID"))
+	require.Equal(t, "HTTPServer", goName("HTTPServer"))
+	require.Equal(t, "X2fa", goName("2fa"))
+	require.Equal(t, "petID", argName("pet_id"))
+	require.Equal(t, "typeParam", argName("type"))
+	require.Equal(t, "bodyParam", argName("body"))
+}
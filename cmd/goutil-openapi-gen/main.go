@@ -0,0 +1,80 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// goutil-openapi-gen 根据OpenAPI 3文档生成基于httpclient.Request的客户端
+//
+// 用法:
+//
+//	//go:generate goutil-openapi-gen -spec petstore.yaml -package petstore -output petstore.go
+//
+// 生成内容:
+//
+//	components.schemas        模型struct, 枚举生成常量和Valid方法
+//	paths                     Client的方法, 路径参数作为方法参数, query、header、cookie参数合并为XxxParams
+//	responses                 2xx的JSON响应解码为返回值, 非2xx返回*ResponseError
+//	securitySchemes           WithXxx认证选项, 传给NewClient
+//	x-pagination              额外生成XxxPages方法, 返回*httpclient.Paginator
+//
+// x-pagination:
+//
+//	type: link|cursor|page|offset
+//	param: 游标、页码或偏移量的query参数
+//	cursor: 响应中游标的JSON pointer, cursor分页必填
+//	items: 响应中列表的JSON pointer
+//	start: 起始页码, 默认1
+//	pageSize: offset分页的每页数量
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	spec := flag.String("spec", "", "OpenAPI 3 document, YAML or JSON; required")
+	pkg := flag.String("package", "", "package name; default output directory name")
+	output := flag.String("output", "client.go", "output file name")
+	client := flag.String("client", "Client", "client type name")
+	flag.Parse()
+	if *spec == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *pkg == "" {
+		abs, err := filepath.Abs(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "goutil-openapi-gen: %s\n", err)
+			os.Exit(1)
+		}
+		*pkg = filepath.Base(filepath.Dir(abs))
+	}
+	doc, err := loadDocument(*spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "goutil-openapi-gen: %s\n", err)
+		os.Exit(1)
+	}
+	src, err := generate(doc, *pkg, *client)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "goutil-openapi-gen: %s\n", err)
+		os.Exit(1)
+	}
+	if err = ioutil.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "goutil-openapi-gen: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPI 3文档中生成代码需要的部分, JSON是YAML的子集, 统一按YAML解析
+type document struct {
+	OpenAPI    string                `yaml:"openapi"`
+	Info       info                  `yaml:"info"`
+	Servers    []server              `yaml:"servers"`
+	Paths      map[string]*pathItem  `yaml:"paths"`
+	Components components            `yaml:"components"`
+	Security   []map[string][]string `yaml:"security"`
+}
+
+type info struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	Version     string `yaml:"version"`
+}
+
+type server struct {
+	URL string `yaml:"url"`
+}
+
+type components struct {
+	Schemas         map[string]*schema         `yaml:"schemas"`
+	Parameters      map[string]*parameter      `yaml:"parameters"`
+	RequestBodies   map[string]*requestBody    `yaml:"requestBodies"`
+	Responses       map[string]*response       `yaml:"responses"`
+	SecuritySchemes map[string]*securityScheme `yaml:"securitySchemes"`
+}
+
+type pathItem struct {
+	Parameters []*parameter `yaml:"parameters"`
+	Get        *operation   `yaml:"get"`
+	Put        *operation   `yaml:"put"`
+	Post       *operation   `yaml:"post"`
+	Delete     *operation   `yaml:"delete"`
+	Options    *operation   `yaml:"options"`
+	Head       *operation   `yaml:"head"`
+	Patch      *operation   `yaml:"patch"`
+}
+
+type operation struct {
+	OperationID string               `yaml:"operationId"`
+	Summary     string               `yaml:"summary"`
+	Description string               `yaml:"description"`
+	Parameters  []*parameter         `yaml:"parameters"`
+	RequestBody *requestBody         `yaml:"requestBody"`
+	Responses   map[string]*response `yaml:"responses"`
+	Deprecated  bool                 `yaml:"deprecated"`
+	Pagination  *pagination          `yaml:"x-pagination"`
+}
+
+// pagination 扩展字段x-pagination, 对应httpclient的分页方式
+type pagination struct {
+	// Type link、cursor、page、offset
+	Type string `yaml:"type"`
+	// Param 游标、页码或偏移量的query参数
+	Param string `yaml:"param"`
+	// Cursor 响应中游标的JSON pointer
+	Cursor string `yaml:"cursor"`
+	// Items 响应中列表的JSON pointer
+	Items string `yaml:"items"`
+	// Start 起始页码, 默认1
+	Start *int `yaml:"start"`
+	// PageSize offset分页的每页数量
+	PageSize int `yaml:"pageSize"`
+}
+
+type parameter struct {
+	Ref         string  `yaml:"$ref"`
+	Name        string  `yaml:"name"`
+	In          string  `yaml:"in"`
+	Description string  `yaml:"description"`
+	Required    bool    `yaml:"required"`
+	Schema      *schema `yaml:"schema"`
+}
+
+type requestBody struct {
+	Ref         string                `yaml:"$ref"`
+	Description string                `yaml:"description"`
+	Required    bool                  `yaml:"required"`
+	Content     map[string]*mediaType `yaml:"content"`
+}
+
+type response struct {
+	Ref         string                `yaml:"$ref"`
+	Description string                `yaml:"description"`
+	Content     map[string]*mediaType `yaml:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `yaml:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `yaml:"$ref"`
+	Type                 string             `yaml:"type"`
+	Format               string             `yaml:"format"`
+	Description          string             `yaml:"description"`
+	Enum                 []interface{}      `yaml:"enum"`
+	Properties           map[string]*schema `yaml:"properties"`
+	Required             []string           `yaml:"required"`
+	Items                *schema            `yaml:"items"`
+	AdditionalProperties *additional        `yaml:"additionalProperties"`
+	AllOf                []*schema          `yaml:"allOf"`
+	OneOf                []*schema          `yaml:"oneOf"`
+	AnyOf                []*schema          `yaml:"anyOf"`
+	Nullable             bool               `yaml:"nullable"`
+}
+
+// additional additionalProperties可以是bool或schema
+type additional struct {
+	Allowed bool
+	Schema  *schema
+}
+
+func (a *additional) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&a.Allowed)
+	}
+	a.Allowed = true
+
+	return node.Decode(&a.Schema)
+}
+
+type securityScheme struct {
+	Type   string `yaml:"type"`
+	Scheme string `yaml:"scheme"`
+	In     string `yaml:"in"`
+	Name   string `yaml:"name"`
+}
+
+func loadDocument(filename string) (*document, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	doc := &document{}
+	if err = yaml.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %s", filename, err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("%s: unsupported openapi version %q, only 3.x is supported", filename, doc.OpenAPI)
+	}
+
+	return doc, nil
+}
+
+// refName #/components/schemas/Pet -> Pet
+func refName(ref, kind string) (string, error) {
+	prefix := "#/components/" + kind + "/"
+	if !strings.HasPrefix(ref, prefix) {
+		return "", fmt.Errorf("unsupported $ref %s, expected %s*", ref, prefix)
+	}
+
+	return strings.TrimPrefix(ref, prefix), nil
+}
@@ -3,9 +3,15 @@ module github.com/ouqiang/goutil
 go 1.12
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/gogo/protobuf v1.2.1
 	github.com/golang/protobuf v1.5.2
 	github.com/google/go-cmp v0.5.9 // indirect
+	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.12.3
 	github.com/prometheus/client_golang v1.12.1
 	github.com/stretchr/testify v1.8.3
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	golang.org/x/net v0.0.0-20210525063256-abc453219eb5
+	gopkg.in/yaml.v3 v3.0.1
 )
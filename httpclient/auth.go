@@ -0,0 +1,81 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// APIKey的位置, 与OpenAPI securitySchemes的in一致
+const (
+	APIKeyInHeader = "header"
+	APIKeyInQuery  = "query"
+	APIKeyInCookie = "cookie"
+)
+
+// WithBearerToken 请求中没有Authorization时添加Authorization: Bearer token
+func WithBearerToken(token string) Option {
+	return withAuth(func(r *http.Request) {
+		if _, ok := r.Header["Authorization"]; !ok {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+	})
+}
+
+// WithBasicAuth 请求中没有Authorization时添加HTTP Basic认证
+func WithBasicAuth(username, password string) Option {
+	return withAuth(func(r *http.Request) {
+		if _, ok := r.Header["Authorization"]; !ok {
+			r.SetBasicAuth(username, password)
+		}
+	})
+}
+
+// WithAPIKey 请求的header、query或cookie中没有name时添加API key, in为APIKeyIn*
+func WithAPIKey(in, name, value string) Option {
+	switch in {
+	case APIKeyInHeader:
+		return withAuth(func(r *http.Request) {
+			if _, ok := r.Header[http.CanonicalHeaderKey(name)]; !ok {
+				r.Header.Set(name, value)
+			}
+		})
+	case APIKeyInQuery:
+		return withAuth(func(r *http.Request) {
+			q := r.URL.Query()
+			if _, ok := q[name]; !ok {
+				q.Set(name, value)
+				r.URL.RawQuery = q.Encode()
+			}
+		})
+	case APIKeyInCookie:
+		return withAuth(func(r *http.Request) {
+			if _, err := r.Cookie(name); err != nil {
+				r.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+		})
+	}
+
+	return func(opt *options) {
+		opt.setError(fmt.Errorf("httpclient: invalid api key location %q", in))
+	}
+}
+
+func withAuth(f func(r *http.Request)) Option {
+	return func(opt *options) {
+		opt.auth = append(opt.auth, f)
+	}
+}
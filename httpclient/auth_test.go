@@ -0,0 +1,73 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_Auth(t *testing.T) {
+	var got *http.Request
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		got = req
+	}))
+	defer s.Close()
+
+	req := NewRequest(
+		WithBearerToken("token"),
+		WithAPIKey(APIKeyInQuery, "api_key", "q-key"),
+		WithAPIKey(APIKeyInHeader, "X-API-Key", "h-key"),
+		WithAPIKey(APIKeyInCookie, "session", "c-key"),
+	)
+	_, err := req.Get(s.URL+"?page=1", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Bearer token", got.Header.Get("Authorization"))
+	require.Equal(t, "q-key", got.URL.Query().Get("api_key"))
+	require.Equal(t, "1", got.URL.Query().Get("page"))
+	require.Equal(t, "h-key", got.Header.Get("X-API-Key"))
+	cookie, err := got.Cookie("session")
+	require.NoError(t, err)
+	require.Equal(t, "c-key", cookie.Value)
+
+	// 请求中已有的值不覆盖
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer caller")
+	header.Set("X-API-Key", "caller-key")
+	header.Set("Cookie", "session=caller")
+	_, err = req.Get(s.URL+"?api_key=caller", nil, header)
+	require.NoError(t, err)
+	require.Equal(t, "Bearer caller", got.Header.Get("Authorization"))
+	require.Equal(t, []string{"caller"}, got.URL.Query()["api_key"])
+	require.Equal(t, []string{"caller-key"}, got.Header["X-Api-Key"])
+	require.Len(t, got.Cookies(), 1)
+	require.Equal(t, "caller", got.Cookies()[0].Value)
+
+	_, err = NewRequest(WithBasicAuth("user", "pass")).Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	username, password, ok := got.BasicAuth()
+	require.True(t, ok)
+	require.Equal(t, "user", username)
+	require.Equal(t, "pass", password)
+
+	_, err = NewRequestWithError(WithAPIKey("body", "key", "value"))
+	require.EqualError(t, err, `httpclient: invalid api key location "body"`)
+	// 不覆盖之前的错误
+	_, err = NewRequestWithError(WithBrowserProfile("netscape"), WithAPIKey("body", "key", "value"))
+	require.EqualError(t, err, `httpclient: unknown browser profile "netscape"`)
+}
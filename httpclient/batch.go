@@ -0,0 +1,167 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// BatchRequest 批量执行中的单个请求, 参数同Request.Do
+type BatchRequest struct {
+	Method string
+	URL    string
+	Data   interface{}
+	Header http.Header
+}
+
+// BatchResult 单个请求的结果, 顺序与请求一致
+type BatchResult struct {
+	Response *Response
+	Err      error
+}
+
+type batchOptions struct {
+	failFast bool
+	check    func(*Response) error
+}
+
+// BatchOption 批量执行可选参数
+type BatchOption func(*batchOptions)
+
+// WithFailFast 任一请求失败时取消其余请求, Batch返回第一个错误
+func WithFailFast() BatchOption {
+	return func(opt *batchOptions) {
+		opt.failFast = true
+	}
+}
+
+// WithBatchResponseCheck 检查响应, 返回的错误视为请求失败, 如非2xx状态码
+func WithBatchResponseCheck(f func(*Response) error) BatchOption {
+	return func(opt *batchOptions) {
+		opt.check = f
+	}
+}
+
+// Batch 并发执行请求, 同时最多concurrency个, concurrency小于等于0时不限制
+// 未开启WithFailFast时返回的error始终为nil, 每个请求的错误记录在BatchResult.Err
+// 开启后失败时其余请求被取消, 未执行的请求Err为context.Canceled
+// 响应body读取依赖Batch内部的context, 所有响应body关闭后释放, 调用方需读取或关闭每个Response
+func Batch(ctx context.Context, client *Request, reqs []*BatchRequest, concurrency int, opt ...BatchOption) ([]*BatchResult, error) {
+	opts := batchOptions{}
+	for _, o := range opt {
+		o(&opts)
+	}
+	if concurrency <= 0 || concurrency > len(reqs) {
+		concurrency = len(reqs)
+	}
+	results := make([]*BatchResult, len(reqs))
+	if len(reqs) == 0 {
+		return results, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	bodies := &batchBodies{cancel: cancel}
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, concurrency)
+	for i, r := range reqs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			results[i] = &BatchResult{Err: err}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, r *BatchRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			resp, err := client.Do(ctx, r.Method, r.URL, r.Data, r.Header)
+			bodies.add(resp)
+			if err == nil && opts.check != nil {
+				err = opts.check(resp)
+			}
+			results[i] = &BatchResult{Response: resp, Err: err}
+			if err != nil && opts.failFast {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i, r)
+	}
+	wg.Wait()
+	bodies.finish()
+
+	return results, firstErr
+}
+
+// batchBodies 所有请求结束且响应body都关闭后取消context
+type batchBodies struct {
+	mu     sync.Mutex
+	open   int
+	done   bool
+	cancel context.CancelFunc
+}
+
+func (b *batchBodies) add(resp *Response) {
+	if resp == nil || resp.rawResp == nil || resp.rawResp.Body == nil {
+		return
+	}
+	b.mu.Lock()
+	b.open++
+	b.mu.Unlock()
+	resp.rawResp.Body = &batchBody{ReadCloser: resp.rawResp.Body, bodies: b}
+}
+
+func (b *batchBodies) release() {
+	b.mu.Lock()
+	b.open--
+	if b.open == 0 && b.done {
+		b.cancel()
+	}
+	b.mu.Unlock()
+}
+
+func (b *batchBodies) finish() {
+	b.mu.Lock()
+	b.done = true
+	if b.open == 0 {
+		b.cancel()
+	}
+	b.mu.Unlock()
+}
+
+type batchBody struct {
+	io.ReadCloser
+	bodies *batchBodies
+	once   sync.Once
+}
+
+func (b *batchBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.bodies.release)
+
+	return err
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	var inFlight, maxInFlight int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		id, _ := strconv.Atoi(req.URL.Query().Get("id"))
+		// 先发出的请求后返回, 验证结果顺序
+		time.Sleep(time.Duration(10-id) * 2 * time.Millisecond)
+		if id == 3 {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = io.WriteString(rw, strconv.Itoa(id))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	var reqs []*BatchRequest
+	for i := 0; i < 10; i++ {
+		reqs = append(reqs, &BatchRequest{Method: http.MethodGet, URL: fmt.Sprintf("%s?id=%d", s.URL, i)})
+	}
+	reqs = append(reqs, &BatchRequest{Method: http.MethodGet, URL: "http://[::1"})
+
+	req := NewRequest()
+	results, err := Batch(context.Background(), req, reqs, 3)
+	require.NoError(t, err)
+	require.Len(t, results, 11)
+	require.True(t, atomic.LoadInt32(&maxInFlight) <= 3)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, results[i].Err)
+		body, err := results[i].Response.String()
+		require.NoError(t, err)
+		require.Equal(t, strconv.Itoa(i), body)
+	}
+	require.Error(t, results[10].Err)
+}
+
+func TestBatch_FailFast(t *testing.T) {
+	var requests int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if req.URL.Query().Get("id") == "0" {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	var reqs []*BatchRequest
+	for i := 0; i < 10; i++ {
+		reqs = append(reqs, &BatchRequest{Method: http.MethodGet, URL: fmt.Sprintf("%s?id=%d", s.URL, i)})
+	}
+	errStatus := errors.New("unexpected status")
+	check := func(resp *Response) error {
+		if !resp.IsStatusOK() {
+			return errStatus
+		}
+		return nil
+	}
+
+	start := time.Now()
+	results, err := Batch(context.Background(), NewRequest(), reqs, 2, WithFailFast(), WithBatchResponseCheck(check))
+	require.Equal(t, errStatus, err)
+	require.True(t, time.Since(start) < 500*time.Millisecond)
+	require.Equal(t, errStatus, results[0].Err)
+	for _, result := range results[2:] {
+		require.True(t, errors.Is(result.Err, context.Canceled))
+	}
+	require.True(t, atomic.LoadInt32(&requests) < 10)
+}
+
+func TestBatch_ReleaseContext(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(rw, "ok")
+	}))
+	defer s.Close()
+
+	var (
+		mu   sync.Mutex
+		ctxs []context.Context
+	)
+	req := NewRequest(WithRequestInterceptor(func(r *http.Request) {
+		mu.Lock()
+		ctxs = append(ctxs, r.Context())
+		mu.Unlock()
+	}))
+	reqs := []*BatchRequest{
+		{Method: http.MethodGet, URL: s.URL},
+		{Method: http.MethodGet, URL: s.URL},
+	}
+	for _, failFast := range []bool{false, true} {
+		ctxs = nil
+		var opt []BatchOption
+		if failFast {
+			opt = append(opt, WithFailFast())
+		}
+		results, err := Batch(context.Background(), req, reqs, 0, opt...)
+		require.NoError(t, err)
+		require.Len(t, ctxs, 2)
+		// body未关闭前context有效
+		body, err := results[0].Response.String()
+		require.NoError(t, err)
+		require.Equal(t, "ok", body)
+		for _, ctx := range ctxs {
+			require.NoError(t, ctx.Err())
+		}
+		require.NoError(t, results[1].Response.Raw().Body.Close())
+		for _, ctx := range ctxs {
+			require.Equal(t, context.Canceled, ctx.Err())
+		}
+	}
+}
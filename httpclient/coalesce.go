@@ -0,0 +1,192 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithCoalescing 合并并发的相同GET请求, 只发起一次请求, 每个调用方获得独立的body副本
+// URL、Authorization、Cookie、Proxy-Authorization、cookie jar中的cookie和headerKeys指定的header值都相同时视为相同请求
+// 合并的请求不受单个调用方取消的影响, 所有调用方都取消后才取消
+func WithCoalescing(headerKeys ...string) Option {
+	return func(opt *options) {
+		opt.coalescing = true
+		opt.coalesceHeaders = headerKeys
+	}
+}
+
+// 总是加入合并key的凭证header, 避免不同用户共享响应
+var coalesceCredentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+type coalesceCall struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+	// refs 等待结果的调用方数量, 由coalesceGroup.mu保护
+	refs int
+	resp *http.Response
+	body []byte
+	err  error
+	// panicValue fn发生panic时的值, 所有调用方重新panic
+	panicValue interface{}
+}
+
+type coalesceGroup struct {
+	mu    sync.Mutex
+	calls map[string]*coalesceCall
+}
+
+func newCoalesceGroup() *coalesceGroup {
+	return &coalesceGroup{
+		calls: make(map[string]*coalesceCall),
+	}
+}
+
+// 相同key的并发调用只执行一次fn, fn使用不随调用方取消的context, 调用方各自等待自己的ctx
+func (g *coalesceGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (*http.Response, []byte, error)) (*coalesceCall, error) {
+	g.mu.Lock()
+	c, ok := g.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(detachedContext{ctx})
+		c = &coalesceCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = c
+		go g.doCall(callCtx, c, key, fn)
+	}
+	c.refs++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		if c.panicValue != nil {
+			panic(c.panicValue)
+		}
+		return c, nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.refs--
+		// 所有调用方都已取消, 之后的调用重新发起请求
+		if c.refs == 0 {
+			c.cancel()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// doCall fn发生panic时也会唤醒等待的调用方并删除key
+func (g *coalesceGroup) doCall(ctx context.Context, c *coalesceCall, key string, fn func(ctx context.Context) (*http.Response, []byte, error)) {
+	defer func() {
+		c.panicValue = recover()
+		c.cancel()
+		g.mu.Lock()
+		if g.calls[key] == c {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.resp, c.body, c.err = fn(ctx)
+}
+
+// detachedContext 保留parent的值, 不继承取消和截止时间
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+func (req *Request) shouldCoalesce(method string, data interface{}) bool {
+	return req.coalesce != nil && method == http.MethodGet && data == nil
+}
+
+func (req *Request) coalesceKey(method, rawURL string, header http.Header) string {
+	var b strings.Builder
+	b.WriteString(method)
+	b.WriteByte(' ')
+	b.WriteString(rawURL)
+	for _, key := range append(coalesceCredentialHeaders[:len(coalesceCredentialHeaders):len(coalesceCredentialHeaders)], req.opts.coalesceHeaders...) {
+		b.WriteByte('\n')
+		b.WriteString(http.CanonicalHeaderKey(key))
+		b.WriteByte(':')
+		if header != nil {
+			b.WriteString(strings.Join(header.Values(key), ","))
+		}
+	}
+	if jar := req.opts.client.Jar; jar != nil {
+		if u, err := url.Parse(rawURL); err == nil {
+			for _, cookie := range jar.Cookies(u) {
+				b.WriteString("\nCookie-Jar:")
+				b.WriteString(cookie.String())
+			}
+		}
+	}
+
+	return b.String()
+}
+
+func (req *Request) doCoalesced(ctx context.Context, method, url string, header http.Header) (*Response, error) {
+	key := req.coalesceKey(method, url, header)
+	c, err := req.coalesce.do(ctx, key, func(ctx context.Context) (*http.Response, []byte, error) {
+		resp, err := req.do(ctx, method, url, nil, header)
+		if err != nil {
+			if resp != nil && resp.rawResp != nil && resp.rawResp.Body != nil {
+				_ = resp.rawResp.Body.Close()
+			}
+			return nil, nil, err
+		}
+		body, err := resp.Bytes()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return resp.rawResp, body, nil
+	})
+	if err != nil {
+		return req.newResponse(nil), err
+	}
+	if c.resp == nil {
+		return req.newResponse(nil), c.err
+	}
+	resp := new(http.Response)
+	*resp = *c.resp
+	resp.Header = c.resp.Header.Clone()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(c.body))
+
+	return req.newResponse(resp), c.err
+}
@@ -0,0 +1,217 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_WithCoalescing(t *testing.T) {
+	var calls int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		rw.Header().Set("X-Tenant", req.Header.Get("X-Tenant"))
+		_, _ = io.WriteString(rw, "config")
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest(WithCoalescing("X-Tenant"))
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			header := make(http.Header)
+			tenant := "a"
+			if i%2 == 1 {
+				tenant = "b"
+			}
+			header.Set("X-Tenant", tenant)
+			resp, err := req.Get(s.URL, nil, header)
+			if err != nil {
+				errs <- err
+				return
+			}
+			body, err := resp.String()
+			if err == nil && (body != "config" || resp.Header().Get("X-Tenant") != tenant) {
+				err = fmt.Errorf("unexpected response %q, tenant %q", body, resp.Header().Get("X-Tenant"))
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	_, err := req.Post(s.URL, "name=golang", nil)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestRequest_WithCoalescingPanic(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(rw, "ok")
+	}))
+	defer s.Close()
+
+	req := NewRequest(
+		WithCoalescing(),
+		WithRequestInterceptor(func(r *http.Request) {
+			if r.URL.Path == "/panic" {
+				// 等待其他调用方加入
+				time.Sleep(50 * time.Millisecond)
+				panic("boom")
+			}
+		}),
+	)
+	var wg sync.WaitGroup
+	panics := make(chan interface{}, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				panics <- recover()
+			}()
+			_, _ = req.Get(s.URL+"/panic", nil, nil)
+		}()
+	}
+	wg.Wait()
+	close(panics)
+	for v := range panics {
+		require.Equal(t, "boom", v)
+	}
+
+	// key已删除, 之后的调用不会阻塞
+	require.PanicsWithValue(t, "boom", func() {
+		_, _ = req.Get(s.URL+"/panic", nil, nil)
+	})
+	resp, err := req.Get(s.URL+"/ok", nil, nil)
+	require.NoError(t, err)
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "ok", body)
+}
+
+func TestRequest_WithCoalescingCredentials(t *testing.T) {
+	var calls int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		_, _ = io.WriteString(rw, req.Header.Get("Authorization")+req.Header.Get("Cookie"))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest(WithCoalescing())
+	headers := []http.Header{
+		{"Authorization": {"Bearer a"}},
+		{"Authorization": {"Bearer b"}},
+		{"Cookie": {"session=a"}},
+		{"Cookie": {"session=b"}},
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, len(headers))
+	for _, header := range headers {
+		wg.Add(1)
+		go func(header http.Header) {
+			defer wg.Done()
+			resp, err := req.Get(s.URL, nil, header)
+			if err != nil {
+				errs <- err
+				return
+			}
+			body, err := resp.String()
+			if want := header.Get("Authorization") + header.Get("Cookie"); err == nil && body != want {
+				err = fmt.Errorf("got %q, want %q", body, want)
+			}
+			errs <- err
+		}(header)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.EqualValues(t, len(headers), atomic.LoadInt32(&calls))
+}
+
+func TestRequest_WithCoalescingCancel(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-release:
+		case <-req.Context().Done():
+			return
+		}
+		_, _ = io.WriteString(rw, "config")
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest(WithCoalescing())
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := req.Do(leaderCtx, http.MethodGet, s.URL, nil, nil)
+		leaderErr <- err
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	type result struct {
+		body string
+		err  error
+	}
+	waiter := make(chan result, 1)
+	go func() {
+		resp, err := req.Get(s.URL, nil, nil)
+		if err != nil {
+			waiter <- result{err: err}
+			return
+		}
+		body, err := resp.String()
+		waiter <- result{body: body, err: err}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// 首个调用方取消后立即返回, 不影响其他调用方
+	cancelLeader()
+	require.True(t, errors.Is(<-leaderErr, context.Canceled))
+	close(release)
+	r := <-waiter
+	require.NoError(t, r.err)
+	require.Equal(t, "config", r.body)
+	require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,208 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// 常用media type
+const (
+	MediaTypeJSON     = "application/json"
+	MediaTypeXML      = "application/xml"
+	MediaTypeProtoBuf = "application/x-protobuf"
+	MediaTypeMsgPack  = "application/x-msgpack"
+	MediaTypeForm     = "application/x-www-form-urlencoded"
+)
+
+// Codec 编解码
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// UnsupportedMediaTypeError 未注册的media type
+type UnsupportedMediaTypeError struct {
+	MediaType string
+}
+
+func (e *UnsupportedMediaTypeError) Error() string {
+	return fmt.Sprintf("httpclient: no codec registered for media type %q", e.MediaType)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		MediaTypeJSON:             jsonCodec{},
+		MediaTypeXML:              xmlCodec{},
+		"text/xml":                xmlCodec{},
+		MediaTypeProtoBuf:         protoBufCodec{},
+		"application/protobuf":    protoBufCodec{},
+		MediaTypeMsgPack:          msgPackCodec{},
+		"application/msgpack":     msgPackCodec{},
+		"application/vnd.msgpack": msgPackCodec{},
+		MediaTypeForm:             formCodec{},
+	}
+)
+
+// RegisterCodec 注册codec, 已存在时覆盖
+func RegisterCodec(mediaType string, c Codec) {
+	codecsMu.Lock()
+	codecs[normalizeMediaType(mediaType)] = c
+	codecsMu.Unlock()
+}
+
+// LookupCodec 根据media type或Content-Type查找codec, 支持+json、+xml后缀
+func LookupCodec(contentType string) (Codec, bool) {
+	mediaType := normalizeMediaType(contentType)
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	if c, ok := codecs[mediaType]; ok {
+		return c, true
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		switch mediaType[i+1:] {
+		case "json":
+			return codecs[MediaTypeJSON], true
+		case "xml":
+			return codecs[MediaTypeXML], true
+		}
+	}
+
+	return nil, false
+}
+
+// 去掉参数并转为小写, 如"application/json; charset=utf-8" => "application/json"
+func normalizeMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+		if i := strings.Index(mediaType, ";"); i >= 0 {
+			mediaType = mediaType[:i]
+		}
+	}
+
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+type protoBufCodec struct{}
+
+func (protoBufCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("httpclient: %T is not proto.Message", v)
+	}
+
+	return proto.Marshal(m)
+}
+
+func (protoBufCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("httpclient: %T is not proto.Message", v)
+	}
+
+	return proto.Unmarshal(data, m)
+}
+
+type msgPackCodec struct{}
+
+func (msgPackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgPackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+type formCodec struct{}
+
+func (formCodec) Marshal(v interface{}) ([]byte, error) {
+	values := url.Values{}
+	switch data := v.(type) {
+	case url.Values:
+		values = data
+	case map[string][]string:
+		values = data
+	case map[string]string:
+		for k, item := range data {
+			values.Set(k, item)
+		}
+	case map[string]interface{}:
+		for k, item := range data {
+			values.Set(k, fmt.Sprint(item))
+		}
+	default:
+		var err error
+		values, err = EncodeForm(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return []byte(values.Encode()), nil
+}
+
+func (formCodec) Unmarshal(data []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	switch target := v.(type) {
+	case *url.Values:
+		*target = values
+	case *map[string][]string:
+		*target = values
+	case *map[string]string:
+		m := make(map[string]string, len(values))
+		for k := range values {
+			m[k] = values.Get(k)
+		}
+		*target = m
+	default:
+		return fmt.Errorf("httpclient: form codec does not support %T", v)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type codecUser struct {
+	Name string `json:"name" xml:"name" msgpack:"name"`
+}
+
+// 原样返回请求body和Content-Type
+func echoHandler(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", req.Header.Get("Content-Type"))
+	_, _ = io.Copy(rw, req.Body)
+}
+
+func TestRequest_PostAs(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer s.Close()
+
+	req := NewRequest()
+	mediaTypes := []string{
+		MediaTypeJSON,
+		MediaTypeXML,
+		MediaTypeMsgPack,
+		"application/problem+json; charset=utf-8",
+		"application/atom+xml",
+	}
+	for _, mediaType := range mediaTypes {
+		resp, err := req.PostAs(s.URL, mediaType, &codecUser{Name: "golang"}, nil)
+		require.NoError(t, err)
+		result := &codecUser{}
+		require.NoError(t, resp.Decode(result), mediaType)
+		require.Equal(t, "golang", result.Name, mediaType)
+	}
+
+	resp, err := req.PostAs(s.URL, MediaTypeProtoBuf, &Message{Name: "protobuf"}, nil)
+	require.NoError(t, err)
+	message := &Message{}
+	require.NoError(t, resp.Decode(message))
+	require.Equal(t, "protobuf", message.Name)
+
+	resp, err = req.PostAs(s.URL, MediaTypeForm, map[string]string{"name": "golang"}, nil)
+	require.NoError(t, err)
+	values := url.Values{}
+	require.NoError(t, resp.Decode(&values))
+	require.Equal(t, "golang", values.Get("name"))
+
+	_, err = req.PostAs(s.URL, "application/unknown", &codecUser{}, nil)
+	var unsupported *UnsupportedMediaTypeError
+	require.True(t, errors.As(err, &unsupported))
+
+	resp, err = req.PostAs(s.URL, "text/plain", "name", nil)
+	require.NoError(t, err)
+	require.True(t, errors.As(resp.Decode(&codecUser{}), &unsupported))
+	require.Equal(t, "text/plain", unsupported.MediaType)
+}
+
+type upperCodec struct{}
+
+func (upperCodec) Marshal(v interface{}) ([]byte, error) {
+	return []byte(strings.ToUpper(v.(string))), nil
+}
+
+func (upperCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*string) = strings.ToLower(string(data))
+	return nil
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec("Text/X-Upper", upperCodec{})
+	defer func() {
+		codecsMu.Lock()
+		delete(codecs, "text/x-upper")
+		codecsMu.Unlock()
+	}()
+	c, ok := LookupCodec("text/x-upper; charset=utf-8")
+	require.True(t, ok)
+	data, err := c.Marshal("golang")
+	require.NoError(t, err)
+	require.Equal(t, "GOLANG", string(data))
+}
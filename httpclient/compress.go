@@ -0,0 +1,310 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrDecompressedSizeExceeded 解压后的大小超过限制
+var ErrDecompressedSizeExceeded = errors.New("httpclient: decompressed body exceeds limit")
+
+// Encoding Content-Encoding编解码
+type Encoding struct {
+	// NewReader 解压, 必须
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+	// NewWriter 压缩, 为nil时不能用于请求压缩
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+var (
+	encodingsMu sync.RWMutex
+	// 按注册顺序生成Accept-Encoding
+	encodingNames = []string{"gzip", "deflate", "br", "zstd"}
+	encodings     = map[string]Encoding{
+		"gzip": {
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return gzip.NewReader(r)
+			},
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return gzip.NewWriter(w), nil
+			},
+		},
+		"deflate": {
+			NewReader: newDeflateReader,
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return zlib.NewWriter(w), nil
+			},
+		},
+		"br": {
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return ioutil.NopCloser(brotli.NewReader(r)), nil
+			},
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return brotli.NewWriter(w), nil
+			},
+		},
+		"zstd": {
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+				if err != nil {
+					return nil, err
+				}
+				return d.IOReadCloser(), nil
+			},
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+			},
+		},
+	}
+)
+
+// RegisterEncoding 注册Content-Encoding, 已存在时覆盖, 内置gzip、deflate、br、zstd
+func RegisterEncoding(name string, e Encoding) {
+	name = strings.ToLower(name)
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	if _, ok := encodings[name]; !ok {
+		encodingNames = append(encodingNames, name)
+	}
+	encodings[name] = e
+}
+
+func lookupEncoding(name string) (Encoding, bool) {
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	e, ok := encodings[strings.ToLower(strings.TrimSpace(name))]
+
+	return e, ok
+}
+
+func acceptEncoding() string {
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+
+	return strings.Join(encodingNames, ", ")
+}
+
+// WithDecompression 自动解压响应, 支持所有已注册的Content-Encoding
+// maxBytes为解压后的最大字节数, 防止压缩炸弹, 小于等于0不限制
+func WithDecompression(maxBytes int64) Option {
+	return func(opt *options) {
+		opt.decompress = true
+		opt.maxDecompressedBytes = maxBytes
+	}
+}
+
+// WithRequestCompression 请求body不小于minSize字节时使用encoding压缩, 并设置Content-Encoding
+// io.Reader类型的body长度未知, 总是边读边压缩
+func WithRequestCompression(encoding string, minSize int) Option {
+	return func(opt *options) {
+		e, ok := lookupEncoding(encoding)
+		if !ok || e.NewWriter == nil {
+			opt.setError(fmt.Errorf("httpclient: unsupported request encoding %q", encoding))
+			return
+		}
+		opt.requestEncoding = strings.ToLower(encoding)
+		opt.requestCompressMinSize = minSize
+	}
+}
+
+// deflate按规范为zlib格式, 部分服务端返回裸deflate, 根据首字节区分
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+
+	return flate.NewReader(br), nil
+}
+
+// 压缩请求body
+// 已知长度的body(string、[]byte、表单)在内存中压缩, 小于minSize时不压缩
+// 其他io.Reader通过io.Pipe边读边压缩, 不缓存整个body
+func (req *Request) compressBody(body io.Reader, header http.Header) (io.Reader, error) {
+	if body == nil || req.opts.requestEncoding == "" || header.Get("Content-Encoding") != "" {
+		return body, nil
+	}
+	e, _ := lookupEncoding(req.opts.requestEncoding)
+	sized, ok := body.(interface{ Len() int })
+	if !ok {
+		header.Set("Content-Encoding", req.opts.requestEncoding)
+		return compressStream(e, body), nil
+	}
+	if sized.Len() < req.opts.requestCompressMinSize {
+		return body, nil
+	}
+	buf := new(bytes.Buffer)
+	w, err := e.NewWriter(buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(w, body); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	header.Set("Content-Encoding", req.opts.requestEncoding)
+
+	return buf, nil
+}
+
+// compressStream 在goroutine中压缩body, 返回的PipeReader被关闭时停止, body实现io.Closer时结束后关闭
+func compressStream(e Encoding, body io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		w, err := e.NewWriter(pw)
+		if err == nil {
+			_, err = io.Copy(w, body)
+			if closeErr := w.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if c, ok := body.(io.Closer); ok {
+			_ = c.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	return pr
+}
+
+// 解压响应body, 解压器在第一次Read时创建
+func (req *Request) decompressResponse(resp *http.Response) error {
+	if !req.opts.decompress || resp == nil || resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+	// HEAD、204、304和空body没有需要解压的内容
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified || resp.ContentLength == 0 ||
+		resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return nil
+	}
+	contentEncoding := resp.Header.Get("Content-Encoding")
+	if contentEncoding == "" || strings.EqualFold(contentEncoding, "identity") {
+		return nil
+	}
+	// 多重编码按相反顺序解压, 有未知编码时不解压
+	names := strings.Split(contentEncoding, ",")
+	decoders := make([]Encoding, 0, len(names))
+	for i := len(names) - 1; i >= 0; i-- {
+		e, ok := lookupEncoding(names[i])
+		if !ok {
+			return nil
+		}
+		decoders = append(decoders, e)
+	}
+	resp.Body = &decompressBody{
+		body:     resp.Body,
+		decoders: decoders,
+		maxBytes: req.opts.maxDecompressedBytes,
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+
+	return nil
+}
+
+type decompressBody struct {
+	body     io.ReadCloser
+	decoders []Encoding
+	maxBytes int64
+	reader   io.Reader
+	closers  []io.Closer
+	err      error
+}
+
+func (b *decompressBody) Read(p []byte) (int, error) {
+	if b.reader == nil && b.err == nil {
+		b.err = b.init()
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	return b.reader.Read(p)
+}
+
+func (b *decompressBody) init() error {
+	var reader io.Reader = b.body
+	for _, e := range b.decoders {
+		r, err := e.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		b.closers = append(b.closers, r)
+		reader = r
+	}
+	if b.maxBytes > 0 {
+		reader = &limitedReader{r: reader, n: b.maxBytes, err: ErrDecompressedSizeExceeded}
+	}
+	b.reader = reader
+
+	return nil
+}
+
+func (b *decompressBody) Close() error {
+	var err error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if e := b.closers[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if e := b.body.Close(); e != nil && err == nil {
+		err = e
+	}
+
+	return err
+}
+
+// 超过n字节时返回err
+type limitedReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, l.err
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n + int(l.n), l.err
+	}
+
+	return n, err
+}
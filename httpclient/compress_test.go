@@ -0,0 +1,255 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func compressWith(t *testing.T, encoding string, data []byte) []byte {
+	buf := new(bytes.Buffer)
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(buf)
+	case "deflate":
+		w = zlib.NewWriter(buf)
+	case "raw-deflate":
+		var err error
+		w, err = flate.NewWriter(buf, flate.DefaultCompression)
+		require.NoError(t, err)
+	case "br":
+		w = brotli.NewWriter(buf)
+	case "zstd":
+		var err error
+		w, err = zstd.NewWriter(buf)
+		require.NoError(t, err)
+	}
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func TestRequest_WithDecompression(t *testing.T) {
+	content := []byte(strings.Repeat("golang", 100))
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		encoding := req.URL.Query().Get("encoding")
+		rw.Header().Set("X-Accept-Encoding", req.Header.Get("Accept-Encoding"))
+		if encoding == "raw-deflate" {
+			rw.Header().Set("Content-Encoding", "deflate")
+		} else {
+			rw.Header().Set("Content-Encoding", encoding)
+		}
+		_, _ = rw.Write(compressWith(t, encoding, content))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest(WithDecompression(0))
+	for _, encoding := range []string{"gzip", "deflate", "raw-deflate", "br", "zstd"} {
+		resp, err := req.Get(s.URL+"?encoding="+encoding, nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, "gzip, deflate, br, zstd", resp.Header().Get("X-Accept-Encoding"))
+		require.Empty(t, resp.Header().Get("Content-Encoding"))
+		body, err := resp.Bytes()
+		require.NoError(t, err)
+		require.Equal(t, content, body, encoding)
+	}
+
+	req = NewRequest(WithDecompression(100))
+	resp, err := req.Get(s.URL+"?encoding=gzip", nil, nil)
+	require.NoError(t, err)
+	body, err := resp.Bytes()
+	require.Equal(t, ErrDecompressedSizeExceeded, err)
+	require.Len(t, body, 100)
+}
+
+func TestRequest_WithRequestCompression(t *testing.T) {
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		var r io.Reader = req.Body
+		switch req.Header.Get("Content-Encoding") {
+		case "gzip":
+			gr, err := gzip.NewReader(req.Body)
+			if err != nil {
+				panic(err)
+			}
+			r = gr
+		case "zstd":
+			zr, err := zstd.NewReader(req.Body)
+			if err != nil {
+				panic(err)
+			}
+			defer zr.Close()
+			r = zr
+		}
+		rw.Header().Set("X-Content-Encoding", req.Header.Get("Content-Encoding"))
+		_, _ = io.Copy(rw, r)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest(WithRequestCompression("gzip", 64))
+	large := strings.Repeat("a", 128)
+	resp, err := req.Post(s.URL, large, nil)
+	require.NoError(t, err)
+	require.Equal(t, "gzip", resp.Header().Get("X-Content-Encoding"))
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, large, body)
+
+	resp, err = req.Post(s.URL, "small", nil)
+	require.NoError(t, err)
+	require.Empty(t, resp.Header().Get("X-Content-Encoding"))
+	body, err = resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "small", body)
+
+	resp, err = NewRequest(WithRequestCompression("zstd", 0)).Post(s.URL, large, nil)
+	require.NoError(t, err)
+	require.Equal(t, "zstd", resp.Header().Get("X-Content-Encoding"))
+	body, err = resp.String()
+	require.NoError(t, err)
+	require.Equal(t, large, body)
+
+	_, err = NewRequestWithError(WithRequestCompression("unknown", 0))
+	require.Error(t, err)
+}
+
+func TestRegisterEncoding(t *testing.T) {
+	RegisterEncoding("x-test", Encoding{
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(r), nil
+		},
+	})
+	defer func() {
+		encodingsMu.Lock()
+		delete(encodings, "x-test")
+		encodingNames = encodingNames[:len(encodingNames)-1]
+		encodingsMu.Unlock()
+	}()
+	require.Equal(t, "gzip, deflate, br, zstd, x-test", acceptEncoding())
+	_, ok := lookupEncoding("X-Test")
+	require.True(t, ok)
+}
+
+func TestRequest_DecompressionWithoutBody(t *testing.T) {
+	content := []byte("golang")
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/multi":
+			rw.Header().Set("Content-Encoding", "gzip, x-unknown")
+			_, _ = rw.Write(compressWith(t, "gzip", content))
+			return
+		case "/no-content":
+			rw.Header().Set("Content-Encoding", "gzip")
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		case "/empty":
+			rw.Header().Set("Content-Encoding", "gzip")
+			rw.(http.Flusher).Flush()
+			return
+		}
+		rw.Header().Set("Content-Encoding", "gzip")
+		_, _ = rw.Write(compressWith(t, "gzip", content))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest(WithDecompression(0))
+	resp, err := req.Do(context.Background(), http.MethodHead, s.URL, nil, nil)
+	require.NoError(t, err)
+	body, err := resp.Bytes()
+	require.NoError(t, err)
+	require.Empty(t, body)
+
+	for _, path := range []string{"/no-content", "/empty"} {
+		resp, err = req.Get(s.URL+path, nil, nil)
+		require.NoError(t, err, path)
+		body, err = resp.Bytes()
+		require.NoError(t, err, path)
+		require.Empty(t, body, path)
+	}
+
+	// 有未知编码时保持原样, 不消耗body
+	resp, err = req.Get(s.URL+"/multi", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, "gzip, x-unknown", resp.Header().Get("Content-Encoding"))
+	body, err = resp.Bytes()
+	require.NoError(t, err)
+	require.Equal(t, compressWith(t, "gzip", content), body)
+}
+
+func TestRequest_WithRequestCompressionStream(t *testing.T) {
+	received := make(chan struct{})
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		gr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		p := make([]byte, 1)
+		if _, err = io.ReadFull(gr, p); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		close(received)
+		n, _ := io.Copy(ioutil.Discard, gr)
+		rw.Header().Set("X-Size", strconv.FormatInt(n+1, 10))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	chunk := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(chunk)
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write(chunk)
+		// 服务端收到数据后才写入剩余部分, 缓存整个body时超时失败
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			_ = pw.CloseWithError(errors.New("body was not streamed"))
+			return
+		}
+		_, _ = pw.Write(chunk)
+		_ = pw.Close()
+	}()
+
+	req := NewRequest(WithRequestCompression("gzip", 1<<30))
+	resp, err := req.Post(s.URL, pr, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.Raw().StatusCode)
+	require.Equal(t, strconv.Itoa(2*len(chunk)), resp.Header().Get("X-Size"))
+}
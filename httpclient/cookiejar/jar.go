@@ -0,0 +1,421 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package cookiejar 可持久化的cookie jar, 遵循public suffix规则
+package cookiejar
+
+import (
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Entry 保存的cookie
+type Entry struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	Path     string    `json:"path"`
+	HostOnly bool      `json:"host_only"`
+	Secure   bool      `json:"secure"`
+	HttpOnly bool      `json:"http_only"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Created  time.Time `json:"created"`
+	seq      uint64
+}
+
+// IsSession 是否为会话cookie
+func (e *Entry) IsSession() bool {
+	return e.Expires.IsZero()
+}
+
+func (e *Entry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !e.Expires.After(now)
+}
+
+func (e *Entry) id() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+func (e *Entry) domainMatch(host string) bool {
+	if e.Domain == host {
+		return true
+	}
+
+	return !e.HostOnly && strings.HasSuffix(host, "."+e.Domain)
+}
+
+func (e *Entry) pathMatch(requestPath string) bool {
+	if requestPath == e.Path {
+		return true
+	}
+	if strings.HasPrefix(requestPath, e.Path) {
+		if e.Path[len(e.Path)-1] == '/' {
+			return true
+		}
+		if requestPath[len(e.Path)] == '/' {
+			return true
+		}
+	}
+
+	return false
+}
+
+type options struct {
+	psl cookiejar.PublicSuffixList
+}
+
+// Option 可选参数
+type Option func(*options)
+
+// WithPublicSuffixList 自定义public suffix列表, 默认使用golang.org/x/net/publicsuffix
+func WithPublicSuffixList(psl cookiejar.PublicSuffixList) Option {
+	return func(opt *options) {
+		opt.psl = psl
+	}
+}
+
+// Jar 实现http.CookieJar
+type Jar struct {
+	opts    options
+	mu      sync.Mutex
+	entries map[string]map[string]*Entry
+	nextSeq uint64
+	now     func() time.Time
+}
+
+// New 创建jar
+func New(opt ...Option) *Jar {
+	j := &Jar{
+		entries: make(map[string]map[string]*Entry),
+		now:     time.Now,
+	}
+	for _, o := range opt {
+		o(&j.opts)
+	}
+	if j.opts.psl == nil {
+		j.opts.psl = publicsuffix.List
+	}
+
+	return j
+}
+
+// Cookies 实现http.CookieJar
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return nil
+	}
+	requestPath := u.Path
+	if requestPath == "" {
+		requestPath = "/"
+	}
+	https := u.Scheme == "https"
+	now := j.now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	submap := j.entries[j.jarKey(host)]
+	var selected []*Entry
+	for id, e := range submap {
+		if e.expired(now) {
+			delete(submap, id)
+			continue
+		}
+		if e.Secure && !https {
+			continue
+		}
+		if !e.domainMatch(host) || !e.pathMatch(requestPath) {
+			continue
+		}
+		selected = append(selected, e)
+	}
+	// path长的优先, 相同时先创建的优先
+	sort.Slice(selected, func(i, k int) bool {
+		if len(selected[i].Path) != len(selected[k].Path) {
+			return len(selected[i].Path) > len(selected[k].Path)
+		}
+		if !selected[i].Created.Equal(selected[k].Created) {
+			return selected[i].Created.Before(selected[k].Created)
+		}
+		return selected[i].seq < selected[k].seq
+	})
+	cookies := make([]*http.Cookie, 0, len(selected))
+	for _, e := range selected {
+		cookies = append(cookies, &http.Cookie{Name: e.Name, Value: e.Value})
+	}
+
+	return cookies
+}
+
+// SetCookies 实现http.CookieJar
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return
+	}
+	defPath := defaultPath(u.Path)
+	now := j.now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		e, remove, ok := j.newEntry(c, host, defPath, now)
+		if !ok {
+			continue
+		}
+		key := j.jarKey(e.Domain)
+		submap := j.entries[key]
+		if remove {
+			delete(submap, e.id())
+			continue
+		}
+		if submap == nil {
+			submap = make(map[string]*Entry)
+			j.entries[key] = submap
+		}
+		if old, ok := submap[e.id()]; ok {
+			e.Created = old.Created
+			e.seq = old.seq
+		} else {
+			e.seq = j.nextSeq
+			j.nextSeq++
+		}
+		submap[e.id()] = e
+	}
+}
+
+// Entries 返回所有未过期的cookie
+func (j *Jar) Entries() []*Entry {
+	now := j.now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var entries []*Entry
+	for _, submap := range j.entries {
+		for _, e := range submap {
+			if e.expired(now) {
+				continue
+			}
+			c := *e
+			entries = append(entries, &c)
+		}
+	}
+	sort.Slice(entries, func(i, k int) bool {
+		return entries[i].id() < entries[k].id()
+	})
+
+	return entries
+}
+
+// Add 添加cookie, 用于从文件加载, 已过期的会被忽略
+func (j *Jar) Add(entries ...*Entry) {
+	now := j.now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, e := range entries {
+		if e.expired(now) || e.Name == "" || e.Domain == "" {
+			continue
+		}
+		c := *e
+		c.Domain = strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+		if c.Path == "" {
+			c.Path = "/"
+		}
+		if c.Created.IsZero() {
+			c.Created = now
+		}
+		c.seq = j.nextSeq
+		j.nextSeq++
+		key := j.jarKey(c.Domain)
+		if j.entries[key] == nil {
+			j.entries[key] = make(map[string]*Entry)
+		}
+		j.entries[key][c.id()] = &c
+	}
+}
+
+// Clear 清空
+func (j *Jar) Clear() {
+	j.mu.Lock()
+	j.entries = make(map[string]map[string]*Entry)
+	j.mu.Unlock()
+}
+
+// 根据Set-Cookie生成Entry, remove为true表示删除已存在的cookie
+func (j *Jar) newEntry(c *http.Cookie, host, defPath string, now time.Time) (e *Entry, remove, ok bool) {
+	e = &Entry{
+		Name:     c.Name,
+		Value:    c.Value,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		Created:  now,
+	}
+	if c.Path == "" || c.Path[0] != '/' {
+		e.Path = defPath
+	} else {
+		e.Path = c.Path
+	}
+
+	var err error
+	e.Domain, e.HostOnly, err = j.domainAndType(host, c.Domain)
+	if err != nil {
+		return nil, false, false
+	}
+
+	switch {
+	case c.MaxAge < 0:
+		return e, true, true
+	case c.MaxAge > 0:
+		e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+	case !c.Expires.IsZero():
+		if !c.Expires.After(now) {
+			return e, true, true
+		}
+		e.Expires = c.Expires
+	}
+
+	return e, false, true
+}
+
+type domainError string
+
+func (e domainError) Error() string {
+	return string(e)
+}
+
+const (
+	errIllegalDomain   = domainError("cookiejar: illegal cookie domain attribute")
+	errNoHostname      = domainError("cookiejar: no host name available")
+	errMalformedDomain = domainError("cookiejar: malformed cookie domain attribute")
+)
+
+// 根据host和Domain属性确定cookie的domain
+func (j *Jar) domainAndType(host, domain string) (string, bool, error) {
+	if domain == "" {
+		return host, true, nil
+	}
+	if isIP(host) {
+		if host != domain {
+			return "", false, errIllegalDomain
+		}
+		return host, true, nil
+	}
+	if domain[0] == '.' {
+		domain = domain[1:]
+	}
+	if len(domain) == 0 || domain[0] == '.' {
+		return "", false, errMalformedDomain
+	}
+	domain = strings.ToLower(domain)
+	if domain[len(domain)-1] == '.' {
+		return "", false, errMalformedDomain
+	}
+	// 不允许为public suffix设置cookie, 如co.uk
+	if j.opts.psl.PublicSuffix(domain) == domain {
+		if host == domain {
+			return host, true, nil
+		}
+		return "", false, errIllegalDomain
+	}
+	if host != domain && !strings.HasSuffix(host, "."+domain) {
+		return "", false, errIllegalDomain
+	}
+
+	return domain, false, nil
+}
+
+// 存储分组的key, 为eTLD+1
+func (j *Jar) jarKey(host string) string {
+	if isIP(host) {
+		return host
+	}
+	key, err := publicsuffixKey(j.opts.psl, host)
+	if err != nil {
+		return host
+	}
+
+	return key
+}
+
+func publicsuffixKey(psl cookiejar.PublicSuffixList, host string) (string, error) {
+	suffix := psl.PublicSuffix(host)
+	if suffix == host {
+		return host, nil
+	}
+	i := len(host) - len(suffix)
+	if i <= 0 || host[i-1] != '.' {
+		return "", errMalformedDomain
+	}
+	prevDot := strings.LastIndex(host[:i-1], ".")
+
+	return host[prevDot+1:], nil
+}
+
+func canonicalHost(host string) (string, error) {
+	if hasPort(host) {
+		h, _, err := net.SplitHostPort(host)
+		if err != nil {
+			return "", err
+		}
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return "", errNoHostname
+	}
+
+	return strings.ToLower(host), nil
+}
+
+func hasPort(host string) bool {
+	colons := strings.Count(host, ":")
+	if colons == 0 {
+		return false
+	}
+	if colons == 1 {
+		return true
+	}
+
+	return host[0] == '[' && strings.Contains(host, "]:")
+}
+
+func isIP(host string) bool {
+	return net.ParseIP(strings.Trim(host, "[]")) != nil
+}
+
+// 默认path, RFC 6265 5.1.4
+func defaultPath(path string) string {
+	if len(path) == 0 || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+
+	return path[:i]
+}
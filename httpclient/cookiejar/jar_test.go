@@ -0,0 +1,116 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cookiejar
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustParseURL(s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return u
+}
+
+func cookieNames(cookies []*http.Cookie) []string {
+	var names []string
+	for _, c := range cookies {
+		names = append(names, c.Name)
+	}
+
+	return names
+}
+
+func TestJar_SetCookies(t *testing.T) {
+	j := New()
+	u := mustParseURL("https://www.example.co.uk/account/login")
+	j.SetCookies(u, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".example.co.uk", Path: "/"},
+		{Name: "suffix", Value: "3", Domain: "co.uk"},
+		{Name: "secure", Value: "4", Secure: true, Path: "/"},
+		{Name: "expired", Value: "5", MaxAge: -1},
+	})
+
+	require.Equal(t, []string{"host", "domain", "secure"}, cookieNames(j.Cookies(u)))
+	require.Equal(t, []string{"domain"}, cookieNames(j.Cookies(mustParseURL("http://api.example.co.uk/"))))
+	require.Empty(t, j.Cookies(mustParseURL("https://other.co.uk/")))
+
+	j.SetCookies(u, []*http.Cookie{{Name: "host", MaxAge: -1}})
+	require.Equal(t, []string{"domain", "secure"}, cookieNames(j.Cookies(u)))
+}
+
+func TestJar_Expiry(t *testing.T) {
+	now := time.Now()
+	j := New()
+	j.now = func() time.Time {
+		return now
+	}
+	u := mustParseURL("http://example.com/")
+	j.SetCookies(u, []*http.Cookie{{Name: "short", Value: "1", MaxAge: 10}})
+	require.Len(t, j.Cookies(u), 1)
+
+	now = now.Add(11 * time.Second)
+	require.Empty(t, j.Cookies(u))
+}
+
+func TestJar_SaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cookiejar")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	u := mustParseURL("https://example.com/")
+	for _, name := range []string{"cookies.json", "cookies.txt"} {
+		j := New()
+		j.SetCookies(u, []*http.Cookie{
+			{Name: "session", Value: "abc", HttpOnly: true},
+			{Name: "persistent", Value: "def", Domain: "example.com", Expires: time.Now().Add(time.Hour)},
+		})
+		filename := filepath.Join(dir, name)
+		require.NoError(t, j.Save(filename))
+
+		loaded := New()
+		require.NoError(t, loaded.Load(filename))
+		require.Equal(t, []string{"persistent", "session"}, cookieNames(sortedCookies(loaded.Cookies(u))))
+		entries := loaded.Entries()
+		require.Len(t, entries, 2)
+		require.False(t, entries[0].HostOnly)
+		require.True(t, entries[1].HostOnly)
+		require.True(t, entries[1].HttpOnly)
+		require.Equal(t, []string{"persistent"}, cookieNames(loaded.Cookies(mustParseURL("https://www.example.com/"))))
+	}
+
+	require.NoError(t, New().Load(filepath.Join(dir, "missing.json")))
+}
+
+func sortedCookies(cookies []*http.Cookie) []*http.Cookie {
+	sort.Slice(cookies, func(i, j int) bool {
+		return cookies[i].Name < cookies[j].Name
+	})
+
+	return cookies
+}
@@ -0,0 +1,190 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package cookiejar
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format 文件格式
+type Format int
+
+const (
+	// FormatJSON json格式
+	FormatJSON Format = iota
+	// FormatNetscape Netscape cookies.txt格式, 兼容curl、wget
+	FormatNetscape
+)
+
+const httpOnlyPrefix = "#HttpOnly_"
+
+// FormatFromFilename 根据扩展名判断格式, .txt为Netscape格式, 其他为json
+func FormatFromFilename(filename string) Format {
+	if strings.ToLower(filepath.Ext(filename)) == ".txt" {
+		return FormatNetscape
+	}
+
+	return FormatJSON
+}
+
+// Save 保存到文件, 格式由扩展名决定, 已过期的cookie不会保存
+func (j *Jar) Save(filename string) error {
+	tmp := filename + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	err = j.Write(f, FormatFromFilename(filename))
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, filename)
+}
+
+// Load 从文件加载, 文件不存在时不返回错误
+func (j *Jar) Load(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return j.Read(f, FormatFromFilename(filename))
+}
+
+// Write 写入w
+func (j *Jar) Write(w io.Writer, format Format) error {
+	entries := j.Entries()
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	case FormatNetscape:
+		return writeNetscape(w, entries)
+	default:
+		return fmt.Errorf("cookiejar: unsupported format %d", format)
+	}
+}
+
+// Read 从r读取并合并到jar中
+func (j *Jar) Read(r io.Reader, format Format) error {
+	var entries []*Entry
+	var err error
+	switch format {
+	case FormatJSON:
+		err = json.NewDecoder(r).Decode(&entries)
+	case FormatNetscape:
+		entries, err = readNetscape(r)
+	default:
+		err = fmt.Errorf("cookiejar: unsupported format %d", format)
+	}
+	if err != nil {
+		return err
+	}
+	j.Add(entries...)
+
+	return nil
+}
+
+func writeNetscape(w io.Writer, entries []*Entry) error {
+	bw := bufio.NewWriter(w)
+	_, _ = bw.WriteString("# Netscape HTTP Cookie File\n\n")
+	for _, e := range entries {
+		domain := e.Domain
+		if !e.HostOnly {
+			domain = "." + domain
+		}
+		if e.HttpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expires int64
+		if !e.IsSession() {
+			expires = e.Expires.Unix()
+		}
+		_, _ = fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, netscapeBool(!e.HostOnly), e.Path, netscapeBool(e.Secure), expires, e.Name, e.Value)
+	}
+
+	return bw.Flush()
+}
+
+func readNetscape(r io.Reader) ([]*Entry, error) {
+	var entries []*Entry
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if strings.HasPrefix(line, httpOnlyPrefix) {
+			httpOnly = true
+			line = line[len(httpOnlyPrefix):]
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("cookiejar: invalid netscape cookie at line %d", lineNo)
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cookiejar: invalid expires at line %d: %s", lineNo, err)
+		}
+		e := &Entry{
+			Domain:   strings.TrimPrefix(fields[0], "."),
+			HostOnly: fields[1] != "TRUE",
+			Path:     fields[2],
+			Secure:   fields[3] == "TRUE",
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			e.Expires = time.Unix(expires, 0)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, scanner.Err()
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+
+	return "FALSE"
+}
@@ -0,0 +1,256 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// EncodeForm 结构体编码为表单, 字段名取form tag, 未设置时使用字段名
+//
+//	type Query struct {
+//		Name    string   `form:"name"`
+//		Tags    []string `form:"tags"`             // tags=a&tags=b
+//		Address Address  `form:"address"`          // address.city=x
+//		Items   []Item   `form:"items"`            // items[0].id=1
+//		Remark  string   `form:"remark,omitempty"` // 零值时忽略
+//		Secret  string   `form:"-"`                // 忽略
+//	}
+//
+// 支持map[string]T, 指针, time.Time(RFC3339)和实现encoding.TextMarshaler的类型
+func EncodeForm(v interface{}) (url.Values, error) {
+	values := url.Values{}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return values, nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct, reflect.Map:
+	default:
+		return nil, fmt.Errorf("httpclient: can not encode %T as form", v)
+	}
+	if err := encodeFormValue(values, "", rv); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func encodeFormValue(values url.Values, key string, rv reflect.Value) error {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Type() == timeType {
+		values.Add(key, rv.Interface().(time.Time).Format(time.RFC3339))
+		return nil
+	}
+	if rv.Type().Implements(textMarshalerType) || reflect.PtrTo(rv.Type()).Implements(textMarshalerType) && rv.CanAddr() {
+		var m encoding.TextMarshaler
+		if rv.Type().Implements(textMarshalerType) {
+			m = rv.Interface().(encoding.TextMarshaler)
+		} else {
+			m = rv.Addr().Interface().(encoding.TextMarshaler)
+		}
+		text, err := m.MarshalText()
+		if err != nil {
+			return err
+		}
+		values.Add(key, string(text))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		return encodeFormStruct(values, key, rv)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("httpclient: form map key must be string, got %s", rv.Type().Key())
+		}
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, k int) bool {
+			return keys[i].String() < keys[k].String()
+		})
+		for _, k := range keys {
+			if err := encodeFormValue(values, joinFormKey(key, k.String()), rv.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			values.Add(key, string(rv.Bytes()))
+			return nil
+		}
+		for i := 0; i < rv.Len(); i++ {
+			elem := rv.Index(i)
+			if isFormScalar(elem.Type()) {
+				if err := encodeFormValue(values, key, elem); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := encodeFormValue(values, fmt.Sprintf("%s[%d]", key, i), elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	s, err := formScalar(rv)
+	if err != nil {
+		return fmt.Errorf("httpclient: form field %q: %s", key, err)
+	}
+	values.Add(key, s)
+
+	return nil
+}
+
+func encodeFormStruct(values url.Values, prefix string, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		name, omitEmpty := parseFormTag(field)
+		if name == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+		if omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		// 匿名结构体且未设置tag时展开
+		if field.Anonymous && field.Tag.Get("form") == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := encodeFormValue(values, prefix, fv); err != nil {
+					return err
+				}
+				continue
+			}
+			if field.PkgPath != "" {
+				continue
+			}
+		}
+		if err := encodeFormValue(values, joinFormKey(prefix, name), fv); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseFormTag(field reflect.StructField) (name string, omitEmpty bool) {
+	tag := field.Tag.Get("form")
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+
+	return name, omitEmpty
+}
+
+func joinFormKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "." + name
+}
+
+func isFormScalar(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType || t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Interface:
+		return false
+	}
+
+	return true
+}
+
+func formScalar(rv reflect.Value) (string, error) {
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+	}
+
+	return "", fmt.Errorf("unsupported type %s", rv.Type())
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).IsZero()
+		}
+	}
+
+	return false
+}
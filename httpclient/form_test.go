@@ -0,0 +1,99 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type formAddress struct {
+	City string `form:"city"`
+	Zip  string `form:"zip,omitempty"`
+}
+
+type formItem struct {
+	ID    int     `form:"id"`
+	Price float64 `form:"price"`
+}
+
+type formBase struct {
+	Source string `form:"source"`
+}
+
+type formOrder struct {
+	formBase
+	Name      string            `form:"name"`
+	Tags      []string          `form:"tags"`
+	Address   formAddress       `form:"address"`
+	Items     []formItem        `form:"items"`
+	Extra     map[string]string `form:"extra"`
+	Paid      *bool             `form:"paid"`
+	Remark    string            `form:"remark,omitempty"`
+	Secret    string            `form:"-"`
+	CreatedAt time.Time         `form:"created_at"`
+	IP        net.IP            `form:"ip"`
+	Untagged  int
+}
+
+func TestEncodeForm(t *testing.T) {
+	paid := true
+	order := &formOrder{
+		formBase:  formBase{Source: "web"},
+		Name:      "golang",
+		Tags:      []string{"a", "b"},
+		Address:   formAddress{City: "shanghai"},
+		Items:     []formItem{{ID: 1, Price: 9.9}, {ID: 2, Price: 10}},
+		Extra:     map[string]string{"k": "v"},
+		Paid:      &paid,
+		Secret:    "secret",
+		CreatedAt: time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+		IP:        net.ParseIP("127.0.0.1"),
+		Untagged:  3,
+	}
+	values, err := EncodeForm(order)
+	require.NoError(t, err)
+	expected := url.Values{
+		"source":         {"web"},
+		"name":           {"golang"},
+		"tags":           {"a", "b"},
+		"address.city":   {"shanghai"},
+		"items[0].id":    {"1"},
+		"items[0].price": {"9.9"},
+		"items[1].id":    {"2"},
+		"items[1].price": {"10"},
+		"extra.k":        {"v"},
+		"paid":           {"true"},
+		"created_at":     {"2018-01-02T03:04:05Z"},
+		"ip":             {"127.0.0.1"},
+		"Untagged":       {"3"},
+	}
+	require.Equal(t, expected, values)
+
+	_, err = EncodeForm(1)
+	require.Error(t, err)
+	_, err = EncodeForm(struct {
+		C chan int
+	}{make(chan int)})
+	require.Error(t, err)
+
+	values, err = EncodeForm((*formOrder)(nil))
+	require.NoError(t, err)
+	require.Empty(t, values)
+}
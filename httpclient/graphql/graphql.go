@@ -0,0 +1,239 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package graphql 基于httpclient.Request的GraphQL客户端
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ouqiang/goutil/httpclient"
+)
+
+// Location 错误在query中的位置
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Error GraphQL错误
+type Error struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Path) == 0 {
+		return "graphql: " + e.Message
+	}
+	path := make([]string, 0, len(e.Path))
+	for _, p := range e.Path {
+		path = append(path, fmt.Sprint(p))
+	}
+
+	return fmt.Sprintf("graphql: %s (path: %s)", e.Message, strings.Join(path, "."))
+}
+
+// Errors 响应中的errors[], 存在时data可能只有部分结果
+type Errors []*Error
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// HTTPError 响应不是有效的GraphQL响应
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("graphql: unexpected http status %d: %s", e.StatusCode, e.Body)
+}
+
+// Request GraphQL请求
+type Request struct {
+	Query         string                 `json:"query,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors Errors          `json:"errors"`
+}
+
+type options struct {
+	header         http.Header
+	persistedQuery bool
+}
+
+// Option 可选参数
+type Option func(*options)
+
+// WithHeader 每个请求附加的header, 如Authorization
+func WithHeader(header http.Header) Option {
+	return func(opt *options) {
+		opt.header = header
+	}
+}
+
+// WithPersistedQueries 使用Automatic Persisted Queries, 先只发送query的sha256, 服务端未缓存时再发送完整query
+func WithPersistedQueries() Option {
+	return func(opt *options) {
+		opt.persistedQuery = true
+	}
+}
+
+// Client GraphQL客户端
+type Client struct {
+	opts     options
+	endpoint string
+	req      *httpclient.Request
+}
+
+// NewClient 创建客户端, req为nil时使用默认配置
+func NewClient(endpoint string, req *httpclient.Request, opt ...Option) *Client {
+	c := &Client{
+		endpoint: endpoint,
+		req:      req,
+	}
+	for _, o := range opt {
+		o(&c.opts)
+	}
+	if c.req == nil {
+		c.req = httpclient.NewRequest()
+	}
+
+	return c
+}
+
+// Query 执行query或mutation, data解码到out
+func (c *Client) Query(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	return c.Do(ctx, &Request{Query: query, Variables: variables}, out)
+}
+
+// Do 执行请求, data解码到out, 响应包含errors时返回Errors
+// variables中包含*Upload时按GraphQL multipart request规范上传文件
+func (c *Client) Do(ctx context.Context, r *Request, out interface{}) error {
+	if hasUpload(r.Variables) {
+		return c.doMultipart(ctx, r, out)
+	}
+	if !c.opts.persistedQuery || r.Query == "" {
+		return c.doJSON(ctx, r, out)
+	}
+
+	hashed := *r
+	hashed.Query = ""
+	hashed.Extensions = persistedQueryExtensions(r)
+	err := c.doJSON(ctx, &hashed, out)
+	if !isPersistedQueryNotFound(err) {
+		return err
+	}
+	withQuery := hashed
+	withQuery.Query = r.Query
+
+	return c.doJSON(ctx, &withQuery, out)
+}
+
+func (c *Client) doJSON(ctx context.Context, r *Request, out interface{}) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	header := c.header()
+	header.Set("Content-Type", httpclient.MediaTypeJSON)
+
+	return c.send(ctx, body, header, out)
+}
+
+func (c *Client) send(ctx context.Context, body interface{}, header http.Header, out interface{}) error {
+	header.Set("Accept", "application/graphql-response+json, application/json")
+	resp, err := c.req.Do(ctx, http.MethodPost, c.endpoint, body, header)
+	if err != nil {
+		return err
+	}
+	data, err := resp.Bytes()
+	if err != nil {
+		return err
+	}
+	result := &response{}
+	if err = json.Unmarshal(data, result); err != nil || (result.Data == nil && result.Errors == nil) {
+		return &HTTPError{StatusCode: resp.Raw().StatusCode, Body: string(data)}
+	}
+	if out != nil && len(result.Data) > 0 && string(result.Data) != "null" {
+		if err = json.Unmarshal(result.Data, out); err != nil {
+			return err
+		}
+	}
+	if len(result.Errors) > 0 {
+		return result.Errors
+	}
+
+	return nil
+}
+
+func (c *Client) header() http.Header {
+	header := make(http.Header)
+	for k, v := range c.opts.header {
+		header[k] = append([]string(nil), v...)
+	}
+
+	return header
+}
+
+func persistedQueryExtensions(r *Request) map[string]interface{} {
+	sum := sha256.Sum256([]byte(r.Query))
+	extensions := make(map[string]interface{}, len(r.Extensions)+1)
+	for k, v := range r.Extensions {
+		extensions[k] = v
+	}
+	extensions["persistedQuery"] = map[string]interface{}{
+		"version":    1,
+		"sha256Hash": hex.EncodeToString(sum[:]),
+	}
+
+	return extensions
+}
+
+func isPersistedQueryNotFound(err error) bool {
+	errs, ok := err.(Errors)
+	if !ok {
+		return false
+	}
+	for _, e := range errs {
+		if e.Message == "PersistedQueryNotFound" {
+			return true
+		}
+		if code, _ := e.Extensions["code"].(string); code == "PERSISTED_QUERY_NOT_FOUND" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,250 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ouqiang/goutil/httpclient"
+)
+
+func TestClient_Query(t *testing.T) {
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		require.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		body := &Request{}
+		require.NoError(t, json.NewDecoder(req.Body).Decode(body))
+		require.Equal(t, "GetUser", body.OperationName)
+		_, _ = io.WriteString(rw, `{"data":{"user":{"name":"`+body.Variables["name"].(string)+`"}}}`)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer token")
+	c := NewClient(s.URL, nil, WithHeader(header))
+	var out struct {
+		User struct {
+			Name string `json:"name"`
+		} `json:"user"`
+	}
+	err := c.Do(context.Background(), &Request{
+		Query:         "query GetUser($name: String!) { user(name: $name) { name } }",
+		Variables:     map[string]interface{}{"name": "golang"},
+		OperationName: "GetUser",
+	}, &out)
+	require.NoError(t, err)
+	require.Equal(t, "golang", out.User.Name)
+}
+
+func TestClient_Errors(t *testing.T) {
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(rw, `{"data":{"user":null,"version":"1"},"errors":[{"message":"not found","path":["user",0,"name"],"locations":[{"line":1,"column":3}]}]}`)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := NewClient(s.URL, nil)
+	var out struct {
+		Version string `json:"version"`
+	}
+	err := c.Query(context.Background(), "{ user { name } version }", nil, &out)
+	var errs Errors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 1)
+	require.Equal(t, []Location{{Line: 1, Column: 3}}, errs[0].Locations)
+	require.Equal(t, "graphql: not found (path: user.0.name)", errs[0].Error())
+	require.Equal(t, "1", out.Version)
+
+	s.Config.Handler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusBadGateway)
+		_, _ = io.WriteString(rw, "bad gateway")
+	})
+	err = c.Query(context.Background(), "{ version }", nil, nil)
+	var httpErr *HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	require.Equal(t, http.StatusBadGateway, httpErr.StatusCode)
+}
+
+func TestClient_PersistedQueries(t *testing.T) {
+	query := "{ version }"
+	cached := false
+	var requests []*Request
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		body := &Request{}
+		require.NoError(t, json.NewDecoder(req.Body).Decode(body))
+		requests = append(requests, body)
+		if body.Query == "" && !cached {
+			_, _ = io.WriteString(rw, `{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`)
+			return
+		}
+		cached = true
+		_, _ = io.WriteString(rw, `{"data":{"version":"1"}}`)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := NewClient(s.URL, nil, WithPersistedQueries())
+	for i := 0; i < 2; i++ {
+		var out map[string]string
+		require.NoError(t, c.Query(context.Background(), query, nil, &out))
+		require.Equal(t, "1", out["version"])
+	}
+	require.Len(t, requests, 3)
+	require.Empty(t, requests[0].Query)
+	require.Equal(t, query, requests[1].Query)
+	require.Empty(t, requests[2].Query)
+	persisted := requests[2].Extensions["persistedQuery"].(map[string]interface{})
+	sum := sha256.Sum256([]byte(query))
+	require.Equal(t, hex.EncodeToString(sum[:]), persisted["sha256Hash"])
+	require.EqualValues(t, 1, persisted["version"])
+}
+
+func TestClient_Upload(t *testing.T) {
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		require.NoError(t, req.ParseMultipartForm(1<<20))
+		require.Equal(t, `{"0":["variables.file"],"1":["variables.files.1"]}`, req.FormValue("map"))
+		operations := &Request{}
+		require.NoError(t, json.Unmarshal([]byte(req.FormValue("operations")), operations))
+		require.Nil(t, operations.Variables["file"])
+		require.Equal(t, []interface{}{"keep", nil}, operations.Variables["files"])
+
+		file, fh, err := req.FormFile("1")
+		require.NoError(t, err)
+		require.Equal(t, "b.txt", fh.Filename)
+		data, _ := ioutil.ReadAll(file)
+		_, _ = io.WriteString(rw, `{"data":{"upload":"`+string(data)+`"}}`)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := NewClient(s.URL, nil)
+	var out map[string]string
+	err := c.Query(context.Background(), "mutation($file: Upload!, $files: [Upload!]!) { upload(file: $file, files: $files) }", map[string]interface{}{
+		"file":  &Upload{Reader: strings.NewReader("a"), Filename: "a.txt"},
+		"files": []interface{}{"keep", &Upload{Reader: strings.NewReader("b"), Filename: "b.txt", ContentType: "text/plain"}},
+	}, &out)
+	require.NoError(t, err)
+	require.Equal(t, "b", out["upload"])
+}
+
+func TestClient_UploadTyped(t *testing.T) {
+	var (
+		fileMap    string
+		operations string
+		files      []string
+	)
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fileMap = req.FormValue("map")
+		operations = req.FormValue("operations")
+		for i := 0; ; i++ {
+			file, _, err := req.FormFile(strconv.Itoa(i))
+			if err != nil {
+				break
+			}
+			data, _ := ioutil.ReadAll(file)
+			files = append(files, string(data))
+		}
+		_, _ = io.WriteString(rw, `{"data":{"upload":true}}`)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	c := NewClient(s.URL, nil)
+	var out map[string]bool
+	err := c.Query(context.Background(), "mutation($files: [Upload!]!, $named: Named) { upload(files: $files, named: $named) }", map[string]interface{}{
+		"files": []*Upload{
+			{Reader: strings.NewReader("a"), Filename: "a.txt"},
+			{Reader: strings.NewReader("b"), Filename: "b.txt"},
+		},
+		"named": map[string]*Upload{"avatar": {Reader: strings.NewReader("c"), Filename: "c.png"}},
+	}, &out)
+	require.NoError(t, err)
+	require.True(t, out["upload"])
+	require.Equal(t, `{"0":["variables.files.0"],"1":["variables.files.1"],"2":["variables.named.avatar"]}`, fileMap)
+	require.JSONEq(t, `{"files":[null,null],"named":{"avatar":null}}`, operationVariables(t, operations))
+	require.Equal(t, []string{"a", "b", "c"}, files)
+
+	// struct中的*Upload无法替换, 返回错误而不是编码为JSON
+	type input struct {
+		File *Upload `json:"file"`
+	}
+	err = c.Query(context.Background(), "mutation($input: Input!) { upload(input: $input) }", map[string]interface{}{
+		"input": &input{File: &Upload{Reader: strings.NewReader("d"), Filename: "d.txt"}},
+	}, &out)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "variables.input")
+}
+
+func TestClient_UploadRetry(t *testing.T) {
+	var attempts int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		file, _, err := req.FormFile("0")
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := ioutil.ReadAll(file)
+		_, _ = io.WriteString(rw, `{"data":{"upload":"`+string(data)+`"}}`)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	// 重试时重新发送完整的multipart body
+	c := NewClient(s.URL, httpclient.NewRequest(httpclient.WithRetryTime(1), httpclient.WithIdempotencyKey()))
+	var out map[string]string
+	err := c.Query(context.Background(), "mutation($file: Upload!) { upload(file: $file) }", map[string]interface{}{
+		"file": &Upload{Reader: strings.NewReader("a"), Filename: "a.txt"},
+	}, &out)
+	require.NoError(t, err)
+	require.Equal(t, "a", out["upload"])
+	require.EqualValues(t, 2, atomic.LoadInt32(&attempts))
+
+	err = c.Query(context.Background(), "mutation($file: Upload!) { upload(file: $file) }", map[string]interface{}{
+		"file": &Upload{Filename: "a.txt"},
+	}, &out)
+	require.EqualError(t, err, "graphql: variables.file: upload reader is nil")
+}
+
+func operationVariables(t *testing.T, operations string) string {
+	r := &struct {
+		Variables json.RawMessage `json:"variables"`
+	}{}
+	require.NoError(t, json.Unmarshal([]byte(operations), r))
+
+	return string(r.Variables)
+}
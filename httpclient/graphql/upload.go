@@ -0,0 +1,194 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Upload 上传文件, 作为variables的值传入
+type Upload struct {
+	Reader      io.Reader
+	Filename    string
+	ContentType string
+}
+
+type uploadFile struct {
+	path   string
+	upload *Upload
+}
+
+var uploadType = reflect.TypeOf((*Upload)(nil))
+
+// variables的最大嵌套深度, 防止循环引用
+const maxUploadDepth = 32
+
+func hasUpload(v interface{}) bool {
+	return containsUpload(reflect.ValueOf(v), 0)
+}
+
+func containsUpload(v reflect.Value, depth int) bool {
+	if !v.IsValid() || depth > maxUploadDepth {
+		return false
+	}
+	if v.Type() == uploadType {
+		return !v.IsNil()
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return !v.IsNil() && containsUpload(v.Elem(), depth+1)
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if containsUpload(iter.Value(), depth+1) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return false
+		}
+		for i := 0; i < v.Len(); i++ {
+			if containsUpload(v.Index(i), depth+1) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" && containsUpload(v.Field(i), depth+1) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// 复制variables并把*Upload替换为null, 同时收集文件路径, 如variables.files.0
+// 支持任意类型的map、slice、array, struct中包含*Upload时返回错误
+func extractUploads(path string, v reflect.Value, files *[]uploadFile) (interface{}, error) {
+	if !containsUpload(v, 0) {
+		if !v.IsValid() {
+			return nil, nil
+		}
+		return v.Interface(), nil
+	}
+	if v.Type() == uploadType {
+		*files = append(*files, uploadFile{path: path, upload: v.Interface().(*Upload)})
+		return nil, nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return extractUploads(path, v.Elem(), files)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("graphql: %s: *Upload in map with non-string keys %s is not supported", path, v.Type())
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		m := make(map[string]interface{}, len(keys))
+		for _, k := range keys {
+			item, err := extractUploads(path+"."+k.String(), v.MapIndex(k), files)
+			if err != nil {
+				return nil, err
+			}
+			m[k.String()] = item
+		}
+		return m, nil
+	case reflect.Slice, reflect.Array:
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			item, err := extractUploads(path+"."+strconv.Itoa(i), v.Index(i), files)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = item
+		}
+		return s, nil
+	}
+
+	return nil, fmt.Errorf("graphql: %s: *Upload in %s is not supported, use map[string]interface{} or a slice", path, v.Type())
+}
+
+// 按https://github.com/jaydenseric/graphql-multipart-request-spec 构造请求
+func (c *Client) doMultipart(ctx context.Context, r *Request, out interface{}) error {
+	var files []uploadFile
+	operation := *r
+	variables, err := extractUploads("variables", reflect.ValueOf(r.Variables), &files)
+	if err != nil {
+		return err
+	}
+	operation.Variables = variables.(map[string]interface{})
+	operations, err := json.Marshal(&operation)
+	if err != nil {
+		return err
+	}
+	fileMap := make(map[string][]string, len(files))
+	for i, f := range files {
+		if f.upload.Reader == nil {
+			return fmt.Errorf("graphql: %s: upload reader is nil", f.path)
+		}
+		fileMap[strconv.Itoa(i)] = []string{f.path}
+	}
+	mapData, err := json.Marshal(fileMap)
+	if err != nil {
+		return err
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err = writer.WriteField("operations", string(operations)); err != nil {
+		return err
+	}
+	if err = writer.WriteField("map", string(mapData)); err != nil {
+		return err
+	}
+	for i, f := range files {
+		contentType := f.upload.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%d"; filename=%q`, i, f.upload.Filename))
+		h.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(part, f.upload.Reader); err != nil {
+			return err
+		}
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+
+	header := c.header()
+	header.Set("Content-Type", writer.FormDataContentType())
+
+	// 使用[]byte, 重试时可重新发送
+	return c.send(ctx, body.Bytes(), header, out)
+}
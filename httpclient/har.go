@@ -0,0 +1,639 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	harVersion    = "1.2"
+	harTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+	// HARRedactedValue 脱敏后的值
+	HARRedactedValue = "[REDACTED]"
+	// 默认每个body最多记录1MB
+	defaultHARBodyLimit = 1 << 20
+)
+
+// HAR HAR 1.2文档, http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog 日志
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator HARCreator  `json:"creator"`
+	Entries []*HAREntry `json:"entries"`
+}
+
+// HARCreator 生成工具
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry 一次请求尝试
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARNameValue header、query、cookie
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARRequest 请求
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARPostData 请求body
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARResponse 响应, 请求失败时Status为0
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARContent 响应body
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARTimings 各阶段耗时(毫秒), -1表示不适用
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+type harOptions struct {
+	bodyLimit     int
+	redactHeaders []string
+	redactQuery   []string
+	redactBody    func(mimeType string, body []byte) []byte
+}
+
+// HAROption HAR录制可选参数
+type HAROption func(*harOptions)
+
+// WithHARBodyLimit 每个请求、响应body最多记录的字节数, 超出部分截断, 小于0时不记录body
+func WithHARBodyLimit(n int) HAROption {
+	return func(opt *harOptions) {
+		opt.bodyLimit = n
+	}
+}
+
+// WithHARRedactHeaders 脱敏的header, 默认包含Authorization、Proxy-Authorization、Cookie、Set-Cookie
+func WithHARRedactHeaders(keys ...string) HAROption {
+	return func(opt *harOptions) {
+		opt.redactHeaders = append(opt.redactHeaders, keys...)
+	}
+}
+
+// WithHARRedactQuery 脱敏的query参数, 如access_token
+func WithHARRedactQuery(keys ...string) HAROption {
+	return func(opt *harOptions) {
+		opt.redactQuery = append(opt.redactQuery, keys...)
+	}
+}
+
+// WithHARRedactBody 请求、响应body脱敏
+func WithHARRedactBody(f func(mimeType string, body []byte) []byte) HAROption {
+	return func(opt *harOptions) {
+		opt.redactBody = f
+	}
+}
+
+// HARRecorder 录制每次请求尝试(包括重试、对冲请求)为HAR, 并发安全
+type HARRecorder struct {
+	opts    harOptions
+	mu      sync.Mutex
+	entries []*HAREntry
+}
+
+// NewHARRecorder 创建HAR录制器, 通过WithHARRecorder启用
+func NewHARRecorder(opt ...HAROption) *HARRecorder {
+	r := &HARRecorder{}
+	r.opts.bodyLimit = defaultHARBodyLimit
+	r.opts.redactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+	for _, o := range opt {
+		o(&r.opts)
+	}
+
+	return r
+}
+
+// WithHARRecorder 录制请求为HAR
+func WithHARRecorder(r *HARRecorder) Option {
+	return func(opt *options) {
+		opt.harRecorder = r
+	}
+}
+
+// HAR 当前录制内容的快照
+func (r *HARRecorder) HAR() *HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := make([]*HAREntry, len(r.entries))
+	for i, e := range r.entries {
+		entry := *e
+		entries[i] = &entry
+	}
+	sort.SliceStable(entries, func(i, k int) bool {
+		return entries[i].StartedDateTime < entries[k].StartedDateTime
+	})
+
+	return &HAR{
+		Log: HARLog{
+			Version: harVersion,
+			Creator: HARCreator{Name: "github.com/ouqiang/goutil/httpclient", Version: harVersion},
+			Entries: entries,
+		},
+	}
+}
+
+// WriteTo 输出HAR json
+func (r *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+
+	return int64(n), err
+}
+
+// WriteFile 输出HAR到文件
+func (r *HARRecorder) WriteFile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err = r.WriteTo(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Reset 清空已录制的记录
+func (r *HARRecorder) Reset() {
+	r.mu.Lock()
+	r.entries = nil
+	r.mu.Unlock()
+}
+
+func (r *HARRecorder) wrap(rt http.RoundTripper) http.RoundTripper {
+	return &harTransport{recorder: r, rt: rt}
+}
+
+type harTransport struct {
+	recorder *HARRecorder
+	rt       http.RoundTripper
+}
+
+// harTrace 记录httptrace各阶段时间点
+type harTrace struct {
+	mu                sync.Mutex
+	start             time.Time
+	dnsStart          time.Time
+	dnsDone           time.Time
+	connectStart      time.Time
+	connectDone       time.Time
+	tlsStart          time.Time
+	tlsDone           time.Time
+	gotConn           time.Time
+	wroteRequest      time.Time
+	firstResponseByte time.Time
+	remoteAddr        string
+}
+
+func (t *harTrace) set(field *time.Time) func() {
+	return func() {
+		t.mu.Lock()
+		if field.IsZero() {
+			*field = time.Now()
+		}
+		t.mu.Unlock()
+	}
+}
+
+func (t *harTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.set(&t.gotConn)()
+			t.mu.Lock()
+			if info.Conn != nil {
+				t.remoteAddr = info.Conn.RemoteAddr().String()
+			}
+			t.mu.Unlock()
+		},
+		DNSStart:             func(httptrace.DNSStartInfo) { t.set(&t.dnsStart)() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone)() },
+		ConnectStart:         func(string, string) { t.set(&t.connectStart)() },
+		ConnectDone:          func(string, string, error) { t.set(&t.connectDone)() },
+		TLSHandshakeStart:    t.set(&t.tlsStart),
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.set(&t.tlsDone)() },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest)() },
+		GotFirstResponseByte: t.set(&t.firstResponseByte),
+	}
+}
+
+func harDuration(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return -1
+	}
+
+	return float64(end.Sub(start)) / float64(time.Millisecond)
+}
+
+func nonNegative(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+
+	return v
+}
+
+// timings 计算各阶段耗时, end为响应body读取完成时间
+func (t *harTrace) timings(end time.Time) HARTimings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timings := HARTimings{
+		Blocked: harDuration(t.start, t.gotConn),
+		DNS:     harDuration(t.dnsStart, t.dnsDone),
+		Connect: harDuration(t.connectStart, t.connectDone),
+		SSL:     harDuration(t.tlsStart, t.tlsDone),
+		Send:    nonNegative(harDuration(t.gotConn, t.wroteRequest)),
+		Wait:    nonNegative(harDuration(t.wroteRequest, t.firstResponseByte)),
+		Receive: nonNegative(harDuration(t.firstResponseByte, end)),
+	}
+	// HAR中connect包含ssl, blocked不包含dns和connect
+	if timings.Connect >= 0 && timings.SSL >= 0 {
+		timings.Connect += timings.SSL
+	}
+	if timings.Blocked >= 0 {
+		for _, v := range []float64{timings.DNS, timings.Connect} {
+			if v > 0 {
+				timings.Blocked -= v
+			}
+		}
+		timings.Blocked = nonNegative(timings.Blocked)
+	}
+
+	return timings
+}
+
+func (t *harTransport) unwrap() http.RoundTripper {
+	return t.rt
+}
+
+func (t *harTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rec := t.recorder
+	trace := &harTrace{start: time.Now()}
+	ctx := httptrace.WithClientTrace(r.Context(), trace.clientTrace())
+	target := r.WithContext(ctx)
+
+	entry := &HAREntry{
+		StartedDateTime: trace.start.Format(harTimeFormat),
+		Request:         rec.harRequest(r),
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		body, replaced, err := readRequestBody(r, rec.opts.bodyLimit)
+		if err != nil {
+			return nil, err
+		}
+		if replaced != nil {
+			target.Body = replaced
+		}
+		entry.Request.BodySize = int64(len(body))
+		if len(body) > rec.opts.bodyLimit {
+			entry.Request.BodySize = -1
+			if r.ContentLength > 0 {
+				entry.Request.BodySize = r.ContentLength
+			}
+		}
+		entry.Request.PostData = rec.harPostData(r.Header.Get("Content-Type"), body)
+	}
+	rec.mu.Lock()
+	rec.entries = append(rec.entries, entry)
+	rec.mu.Unlock()
+
+	resp, err := t.rt.RoundTrip(target)
+	if err != nil {
+		rec.mu.Lock()
+		entry.Response = HARResponse{
+			HTTPVersion: r.Proto,
+			Cookies:     []HARNameValue{},
+			Headers:     []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		}
+		entry.Comment = err.Error()
+		rec.finish(entry, trace, time.Now())
+		rec.mu.Unlock()
+		return resp, err
+	}
+
+	rec.mu.Lock()
+	entry.Response = rec.harResponse(resp)
+	rec.finish(entry, trace, time.Now())
+	rec.mu.Unlock()
+	resp.Body = &harBody{
+		ReadCloser:    resp.Body,
+		recorder:      rec,
+		entry:         entry,
+		trace:         trace,
+		mimeType:      resp.Header.Get("Content-Type"),
+		contentLength: resp.ContentLength,
+	}
+
+	return resp, nil
+}
+
+// readRequestBody 最多读取limit+1字节请求body, 优先使用GetBody避免消耗原body
+// 没有GetBody时replaced非nil, 需替换原body, 由Transport继续读取剩余部分
+func readRequestBody(r *http.Request, limit int) (body []byte, replaced io.ReadCloser, err error) {
+	n := int64(limit) + 1
+	if r.GetBody != nil {
+		if rc, err := r.GetBody(); err == nil {
+			defer rc.Close()
+			body, err = ioutil.ReadAll(io.LimitReader(rc, n))
+			return body, nil, err
+		}
+	}
+	body, err = ioutil.ReadAll(io.LimitReader(r.Body, n))
+	if err != nil {
+		_ = r.Body.Close()
+		return nil, nil, err
+	}
+	replaced = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+	return body, replaced, nil
+}
+
+// finish 调用方需持有锁
+func (r *HARRecorder) finish(entry *HAREntry, trace *harTrace, end time.Time) {
+	entry.Timings = trace.timings(end)
+	entry.Time = harDuration(trace.start, end)
+	trace.mu.Lock()
+	if trace.remoteAddr != "" {
+		entry.Connection = trace.remoteAddr
+		if i := strings.LastIndex(trace.remoteAddr, ":"); i > 0 {
+			entry.ServerIPAddress = strings.Trim(trace.remoteAddr[:i], "[]")
+		}
+	}
+	trace.mu.Unlock()
+}
+
+func (r *HARRecorder) harRequest(req *http.Request) HARRequest {
+	u := *req.URL
+	query := u.Query()
+	for _, key := range r.opts.redactQuery {
+		if _, ok := query[key]; ok {
+			query.Set(key, HARRedactedValue)
+		}
+	}
+	if len(r.opts.redactQuery) > 0 {
+		u.RawQuery = query.Encode()
+	}
+	queryString := []HARNameValue{}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range query[k] {
+			queryString = append(queryString, HARNameValue{Name: k, Value: v})
+		}
+	}
+	cookies := []HARNameValue{}
+	for _, c := range req.Cookies() {
+		cookies = append(cookies, HARNameValue{Name: c.Name, Value: HARRedactedValue})
+	}
+	header := req.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if req.Host != "" && req.Host != req.URL.Host {
+		header.Set("Host", req.Host)
+	}
+
+	return HARRequest{
+		Method:      req.Method,
+		URL:         u.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     cookies,
+		Headers:     r.harHeaders(header),
+		QueryString: queryString,
+		HeadersSize: -1,
+		BodySize:    0,
+	}
+}
+
+func (r *HARRecorder) harResponse(resp *http.Response) HARResponse {
+	cookies := []HARNameValue{}
+	for _, c := range resp.Cookies() {
+		cookies = append(cookies, HARNameValue{Name: c.Name, Value: HARRedactedValue})
+	}
+
+	return HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Cookies:     cookies,
+		Headers:     r.harHeaders(resp.Header),
+		Content: HARContent{
+			MimeType: resp.Header.Get("Content-Type"),
+		},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+}
+
+func (r *HARRecorder) harHeaders(header http.Header) []HARNameValue {
+	redact := make(map[string]bool, len(r.opts.redactHeaders))
+	for _, key := range r.opts.redactHeaders {
+		redact[http.CanonicalHeaderKey(key)] = true
+	}
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	headers := []HARNameValue{}
+	for _, k := range keys {
+		for _, v := range header[k] {
+			if redact[http.CanonicalHeaderKey(k)] {
+				v = HARRedactedValue
+			}
+			headers = append(headers, HARNameValue{Name: k, Value: v})
+		}
+	}
+
+	return headers
+}
+
+// harBodyText 截断并脱敏body, 非utf8内容使用base64
+func (r *HARRecorder) harBodyText(mimeType string, body []byte) (text string, encoding string, comment string) {
+	if r.opts.bodyLimit < 0 {
+		return "", "", "body omitted"
+	}
+	if len(body) > r.opts.bodyLimit {
+		body = body[:r.opts.bodyLimit]
+		comment = "body truncated"
+	}
+	if r.opts.redactBody != nil {
+		body = r.opts.redactBody(mimeType, body)
+	}
+	if utf8.Valid(body) {
+		return string(body), "", comment
+	}
+
+	return base64.StdEncoding.EncodeToString(body), "base64", comment
+}
+
+func (r *HARRecorder) harPostData(mimeType string, body []byte) *HARPostData {
+	text, encoding, comment := r.harBodyText(mimeType, body)
+	if encoding != "" {
+		comment = strings.TrimPrefix(comment+", base64 encoded", ", ")
+	}
+
+	return &HARPostData{MimeType: mimeType, Text: text, Comment: comment}
+}
+
+// harBody 读取响应body时记录内容, 读取完成或关闭时更新entry
+type harBody struct {
+	io.ReadCloser
+	recorder *HARRecorder
+	entry    *HAREntry
+	trace    *harTrace
+	mimeType string
+	// contentLength body未读取完时作为大小
+	contentLength int64
+	buf           bytes.Buffer
+	size          int64
+	eof           bool
+	once          sync.Once
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.size += int64(n)
+		// 多记录1字节用于判断是否截断
+		if remain := b.recorder.opts.bodyLimit + 1 - b.buf.Len(); remain > 0 {
+			if remain > n {
+				remain = n
+			}
+			b.buf.Write(p[:remain])
+		}
+	}
+	if err == io.EOF {
+		b.eof = true
+		b.done()
+	}
+
+	return n, err
+}
+
+// Close 只记录已读取的内容, 未读取完时标记为截断, 不再从网络读取以免阻塞流式响应
+func (b *harBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+
+	return err
+}
+
+func (b *harBody) done() {
+	b.once.Do(func() {
+		end := time.Now()
+		text, encoding, comment := b.recorder.harBodyText(b.mimeType, b.buf.Bytes())
+		if !b.eof && b.recorder.opts.bodyLimit >= 0 {
+			comment = "body truncated"
+		}
+		size := b.size
+		if !b.eof && b.contentLength > size {
+			size = b.contentLength
+		}
+		b.recorder.mu.Lock()
+		b.entry.Response.BodySize = size
+		b.entry.Response.Content.Size = size
+		b.entry.Response.Content.Text = text
+		b.entry.Response.Content.Encoding = encoding
+		b.entry.Response.Content.Comment = comment
+		b.recorder.finish(b.entry, b.trace, end)
+		b.recorder.mu.Unlock()
+	})
+}
@@ -0,0 +1,187 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func harHeader(values []HARNameValue, name string) string {
+	for _, v := range values {
+		if strings.EqualFold(v.Name, name) {
+			return v.Value
+		}
+	}
+
+	return ""
+}
+
+func TestHARRecorder(t *testing.T) {
+	attempts := 0
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		attempts++
+		body, _ := ioutil.ReadAll(req.Body)
+		rw.Header().Set("Set-Cookie", "session=secret")
+		rw.Header().Set("Content-Type", "text/plain")
+		if attempts < 3 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = io.WriteString(rw, "echo:"+string(body)+":password")
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	rec := NewHARRecorder(
+		WithHARBodyLimit(16),
+		WithHARRedactQuery("token"),
+		WithHARRedactBody(func(mimeType string, body []byte) []byte {
+			return bytes.Replace(body, []byte("golang"), []byte(HARRedactedValue), -1)
+		}),
+	)
+	req := NewRequest(WithHARRecorder(rec), WithRetryTime(2), WithIdempotencyKey())
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer secret")
+	resp, err := req.Post(s.URL+"?token=abc&page=1", "name=golang", header)
+	require.NoError(t, err)
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "echo:name=golang:password", body)
+
+	har := rec.HAR()
+	require.Equal(t, "1.2", har.Log.Version)
+	require.Len(t, har.Log.Entries, 3)
+	key := harHeader(har.Log.Entries[0].Request.Headers, IdempotencyKeyHeader)
+	require.NotEmpty(t, key)
+	for i, entry := range har.Log.Entries {
+		require.Equal(t, http.MethodPost, entry.Request.Method)
+		require.Equal(t, key, harHeader(entry.Request.Headers, IdempotencyKeyHeader))
+		require.Contains(t, entry.Request.URL, "token=%5BREDACTED%5D")
+		require.Equal(t, HARRedactedValue, harHeader(entry.Request.Headers, "Authorization"))
+		require.Equal(t, HARRedactedValue, harHeader(entry.Response.Headers, "Set-Cookie"))
+		require.Equal(t, "name="+HARRedactedValue, entry.Request.PostData.Text)
+		require.EqualValues(t, len("name=golang"), entry.Request.BodySize)
+		require.EqualValues(t, len(body), entry.Response.Content.Size)
+		require.Equal(t, "body truncated", entry.Response.Content.Comment)
+		require.True(t, entry.Timings.Send >= 0 && entry.Timings.Wait >= 0 && entry.Timings.Receive >= 0)
+		require.Equal(t, "127.0.0.1", entry.ServerIPAddress)
+		if i < 2 {
+			// 重试前丢弃的响应未读取, 不记录内容
+			require.Equal(t, http.StatusServiceUnavailable, entry.Response.Status)
+			require.Empty(t, entry.Response.Content.Text)
+		} else {
+			require.Equal(t, "echo:name="+HARRedactedValue, entry.Response.Content.Text)
+			require.Equal(t, http.StatusOK, entry.Response.Status)
+			require.Equal(t, "OK", entry.Response.StatusText)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "httpclient")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "traffic.har")
+	require.NoError(t, rec.WriteFile(filename))
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	decoded := &HAR{}
+	require.NoError(t, json.Unmarshal(data, decoded))
+	require.Len(t, decoded.Log.Entries, 3)
+
+	rec.Reset()
+	require.Empty(t, rec.HAR().Log.Entries)
+}
+
+func TestHARRecorder_BinaryAndError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte{0xff, 0xfe, 0x00})
+	}))
+	defer s.Close()
+
+	rec := NewHARRecorder()
+	req := NewRequest(WithHARRecorder(rec))
+	resp, err := req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	_, err = resp.Bytes()
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	_, err = req.Get("http://"+addr, nil, nil)
+	require.Error(t, err)
+
+	buf := &bytes.Buffer{}
+	_, err = rec.WriteTo(buf)
+	require.NoError(t, err)
+	har := &HAR{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), har))
+	require.Len(t, har.Log.Entries, 2)
+	content := har.Log.Entries[0].Response.Content
+	require.Equal(t, "base64", content.Encoding)
+	require.Equal(t, base64.StdEncoding.EncodeToString([]byte{0xff, 0xfe, 0x00}), content.Text)
+	require.Equal(t, 0, har.Log.Entries[1].Response.Status)
+	require.NotEmpty(t, har.Log.Entries[1].Comment)
+}
+
+func TestHARRecorder_StreamingBody(t *testing.T) {
+	done := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		rw.Header().Set("X-Body-Size", strconv.Itoa(len(body)))
+		_, _ = rw.Write(body[:4])
+		rw.(http.Flusher).Flush()
+		select {
+		case <-done:
+		case <-req.Context().Done():
+		}
+	}))
+	defer s.Close()
+	defer close(done)
+
+	rec := NewHARRecorder(WithHARBodyLimit(8))
+	req := NewRequest(WithHARRecorder(rec))
+	// 没有GetBody的请求body只记录bodyLimit字节, 完整发送
+	payload := strings.Repeat("a", 64)
+	resp, err := req.Post(s.URL, io.MultiReader(strings.NewReader(payload)), nil)
+	require.NoError(t, err)
+	require.Equal(t, "64", resp.Raw().Header.Get("X-Body-Size"))
+	p := make([]byte, 4)
+	_, err = io.ReadFull(resp.Raw().Body, p)
+	require.NoError(t, err)
+	start := time.Now()
+	require.NoError(t, resp.Raw().Body.Close())
+	require.True(t, time.Since(start) < time.Second)
+
+	entry := rec.HAR().Log.Entries[0]
+	require.Equal(t, strings.Repeat("a", 8), entry.Request.PostData.Text)
+	require.Equal(t, "body truncated", entry.Request.PostData.Comment)
+	require.Equal(t, "aaaa", entry.Response.Content.Text)
+	require.Equal(t, "body truncated", entry.Response.Content.Comment)
+}
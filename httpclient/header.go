@@ -0,0 +1,158 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// 浏览器配置名称
+const (
+	ProfileChrome        = "chrome"
+	ProfileChromeAndroid = "chrome-android"
+	ProfileEdge          = "edge"
+	ProfileFirefox       = "firefox"
+	ProfileSafari        = "safari"
+	ProfileSafariIOS     = "safari-ios"
+)
+
+// BrowserProfile 浏览器发送的默认header
+type BrowserProfile struct {
+	Name   string
+	Header http.Header
+}
+
+const (
+	chromeAccept  = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"
+	firefoxAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/png,image/svg+xml,*/*;q=0.8"
+	safariAccept  = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+)
+
+var browserProfiles = map[string]*BrowserProfile{
+	ProfileChrome: {
+		Header: chromiumHeader(
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36",
+			`"Google Chrome";v="131", "Chromium";v="131", "Not_A Brand";v="24"`, "?0", `"Windows"`,
+		),
+	},
+	ProfileChromeAndroid: {
+		Header: chromiumHeader(
+			"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Mobile Safari/537.36",
+			`"Google Chrome";v="131", "Chromium";v="131", "Not_A Brand";v="24"`, "?1", `"Android"`,
+		),
+	},
+	ProfileEdge: {
+		Header: chromiumHeader(
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36 Edg/131.0.0.0",
+			`"Microsoft Edge";v="131", "Chromium";v="131", "Not_A Brand";v="24"`, "?0", `"Windows"`,
+		),
+	},
+	ProfileFirefox: {
+		Header: http.Header{
+			"User-Agent":                {"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:133.0) Gecko/20100101 Firefox/133.0"},
+			"Accept":                    {firefoxAccept},
+			"Accept-Language":           {"en-US,en;q=0.5"},
+			"Upgrade-Insecure-Requests": {"1"},
+			"Sec-Fetch-Dest":            {"document"},
+			"Sec-Fetch-Mode":            {"navigate"},
+			"Sec-Fetch-Site":            {"none"},
+			"Sec-Fetch-User":            {"?1"},
+			"Priority":                  {"u=0, i"},
+		},
+	},
+	ProfileSafari: {
+		Header: safariHeader("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.1 Safari/605.1.15"),
+	},
+	ProfileSafariIOS: {
+		Header: safariHeader("Mozilla/5.0 (iPhone; CPU iPhone OS 18_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.1 Mobile/15E148 Safari/604.1"),
+	},
+}
+
+// 不设置Accept-Encoding, 否则不会自动解压
+func chromiumHeader(userAgent, secCHUA, mobile, platform string) http.Header {
+	return http.Header{
+		"User-Agent":                {userAgent},
+		"Accept":                    {chromeAccept},
+		"Accept-Language":           {"en-US,en;q=0.9"},
+		"Sec-Ch-Ua":                 {secCHUA},
+		"Sec-Ch-Ua-Mobile":          {mobile},
+		"Sec-Ch-Ua-Platform":        {platform},
+		"Upgrade-Insecure-Requests": {"1"},
+		"Sec-Fetch-Dest":            {"document"},
+		"Sec-Fetch-Mode":            {"navigate"},
+		"Sec-Fetch-Site":            {"none"},
+		"Sec-Fetch-User":            {"?1"},
+		"Priority":                  {"u=0, i"},
+	}
+}
+
+func safariHeader(userAgent string) http.Header {
+	return http.Header{
+		"User-Agent":      {userAgent},
+		"Accept":          {safariAccept},
+		"Accept-Language": {"en-US,en;q=0.9"},
+		"Sec-Fetch-Dest":  {"document"},
+		"Sec-Fetch-Mode":  {"navigate"},
+		"Sec-Fetch-Site":  {"none"},
+		"Priority":        {"u=0, i"},
+	}
+}
+
+// LookupBrowserProfile 查找浏览器配置, 返回副本
+func LookupBrowserProfile(name string) (*BrowserProfile, bool) {
+	p, ok := browserProfiles[name]
+	if !ok {
+		return nil, false
+	}
+
+	return &BrowserProfile{
+		Name:   name,
+		Header: p.Header.Clone(),
+	}, true
+}
+
+// WithDefaultHeaders 设置默认header, 请求中没有的key才会添加, 多次调用时合并, 相同key以后设置的为准
+func WithDefaultHeaders(header http.Header) Option {
+	return func(opt *options) {
+		if opt.defaultHeader == nil {
+			opt.defaultHeader = make(http.Header)
+		}
+		for k, v := range header {
+			opt.defaultHeader[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+		}
+	}
+}
+
+// WithBrowserProfile 使用浏览器配置的默认header
+func WithBrowserProfile(name string) Option {
+	return func(opt *options) {
+		p, ok := LookupBrowserProfile(name)
+		if !ok {
+			opt.setError(fmt.Errorf("httpclient: unknown browser profile %q", name))
+			return
+		}
+		WithDefaultHeaders(p.Header)(opt)
+	}
+}
+
+// applyDefaultHeader 添加请求中没有的默认header, 调用方设置为空值的header保持不变
+func (req *Request) applyDefaultHeader(r *http.Request) {
+	for k, v := range req.opts.defaultHeader {
+		if _, ok := r.Header[k]; !ok {
+			r.Header[k] = append([]string(nil), v...)
+		}
+	}
+}
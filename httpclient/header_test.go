@@ -0,0 +1,113 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_DefaultHeaders(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		headers []http.Header
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		headers = append(headers, req.Header)
+		attempts := len(headers)
+		mu.Unlock()
+		if attempts < 3 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer s.Close()
+
+	req := NewRequest(
+		WithRetryTime(2),
+		WithDefaultHeaders(http.Header{"x-default": {"a"}, "User-Agent": {"default"}}),
+		WithDefaultHeaders(http.Header{"X-Default": {"b"}, "X-Empty": {"default"}}),
+	)
+	header := http.Header{}
+	header.Set("User-Agent", "caller")
+	// 设置为空值表示不发送该header, 不使用默认值
+	header.Set("X-Empty", "")
+	_, err := req.Get(s.URL, nil, header)
+	require.NoError(t, err)
+	require.Len(t, headers, 3)
+	for _, h := range headers {
+		require.Equal(t, []string{"b"}, h["X-Default"])
+		require.Equal(t, []string{""}, h["X-Empty"])
+		require.Equal(t, []string{"caller"}, h["User-Agent"])
+	}
+}
+
+func TestRequest_BrowserProfile(t *testing.T) {
+	var got *http.Request
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		got = req
+	}))
+	defer s.Close()
+
+	req := NewRequest(WithBrowserProfile(ProfileChrome))
+	_, err := req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	profile, ok := LookupBrowserProfile(ProfileChrome)
+	require.True(t, ok)
+	require.Equal(t, profile.Header.Get("User-Agent"), got.Header.Get("User-Agent"))
+	require.Contains(t, got.Header.Get("Sec-Ch-Ua"), "Chromium")
+	require.Equal(t, "?0", got.Header.Get("Sec-Ch-Ua-Mobile"))
+
+	_, err = NewRequest(WithBrowserProfile(ProfileFirefox)).Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	require.Contains(t, got.Header.Get("User-Agent"), "Firefox")
+	require.Empty(t, got.Header.Get("Sec-Ch-Ua"))
+
+	// 返回副本, 修改不影响内置配置
+	profile.Header.Set("User-Agent", "changed")
+	profile, _ = LookupBrowserProfile(ProfileChrome)
+	require.NotEqual(t, "changed", profile.Header.Get("User-Agent"))
+
+	_, err = NewRequestWithError(WithBrowserProfile("netscape"))
+	require.EqualError(t, err, `httpclient: unknown browser profile "netscape"`)
+	// 不覆盖之前的错误
+	_, err = NewRequestWithError(WithRequestCompression("unknown", 0), WithBrowserProfile("netscape"))
+	require.EqualError(t, err, `httpclient: unsupported request encoding "unknown"`)
+}
+
+func TestRequest_EnableDefaultHeader(t *testing.T) {
+	var got *http.Request
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		got = req
+	}))
+	defer s.Close()
+
+	_, err := NewRequest(WithEnableDefaultHeader()).Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	require.Contains(t, got.Header.Get("User-Agent"), "Chrome/")
+	require.Equal(t, "no-cache", got.Header.Get("Cache-Control"))
+	require.Len(t, got.Header["Accept-Language"], 1)
+	require.Contains(t, got.Header.Get("Accept-Language"), "zh-CN")
+	// 没有设置Accept-Encoding, 由Transport自动解压
+	require.Equal(t, "gzip", got.Header.Get("Accept-Encoding"))
+	// 不发送浏览器导航请求的header
+	require.Empty(t, got.Header.Get("Sec-Fetch-Mode"))
+	require.Empty(t, got.Header.Get("Upgrade-Insecure-Requests"))
+	require.Empty(t, got.Header.Get("Sec-Ch-Ua"))
+}
@@ -0,0 +1,169 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithHedging 开启对冲请求, 只对GET、HEAD请求生效
+// 请求超过delay未响应时再发起一次请求, 最多额外发起maxHedges次, 先成功返回的响应生效, 其余请求被取消
+// 5xx、429响应不会胜出, 继续等待其他请求, 所有请求都结束时才返回最后收到的该类响应
+// delay一般设置为后端的p95延迟
+func WithHedging(delay time.Duration, maxHedges int) Option {
+	return func(opt *options) {
+		opt.hedgingDelay = delay
+		opt.maxHedges = maxHedges
+	}
+}
+
+type hedgeResult struct {
+	index  int
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+}
+
+func (req *Request) shouldHedge(r *http.Request) bool {
+	if req.opts.hedgingDelay <= 0 || req.opts.maxHedges <= 0 {
+		return false
+	}
+
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+// 发起对冲请求, 返回第一个成功的响应
+func (req *Request) doHedged(r *http.Request, metric *Metric) (*http.Response, error) {
+	total := req.opts.maxHedges + 1
+	results := make(chan hedgeResult, total)
+	cancels := make([]context.CancelFunc, 0, total)
+	launch := func() {
+		ctx, cancel := context.WithCancel(r.Context())
+		cancels = append(cancels, cancel)
+		index := len(cancels) - 1
+		attempt := r.Clone(ctx)
+		go func() {
+			resp, err := req.opts.client.Do(attempt)
+			results <- hedgeResult{index: index, resp: resp, err: err, cancel: cancel}
+		}()
+	}
+
+	launch()
+	inflight := 1
+	timer := time.NewTimer(req.opts.hedgingDelay)
+	defer timer.Stop()
+	var (
+		lastErr  error
+		fallback *hedgeResult
+	)
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) < total {
+				launch()
+				inflight++
+				if metric != nil {
+					metric.HedgeFired(r.URL)
+				}
+				timer.Reset(req.opts.hedgingDelay)
+			}
+		case result := <-results:
+			inflight--
+			if result.err == nil && !isHedgeRetryableStatus(result.resp.StatusCode) {
+				for i, cancel := range cancels {
+					if i != result.index {
+						cancel()
+					}
+				}
+				if fallback != nil {
+					_ = fallback.resp.Body.Close()
+				}
+				go discardHedgeResults(results, inflight)
+
+				return hedgeResponse(r, result, metric), nil
+			}
+			if result.err == nil {
+				// 可重试的状态码, 保留响应, 等待其他请求
+				if fallback != nil {
+					_ = fallback.resp.Body.Close()
+					fallback.cancel()
+				}
+				fallback = &result
+			} else {
+				result.cancel()
+				lastErr = result.err
+			}
+			if inflight > 0 {
+				continue
+			}
+			if len(cancels) >= total || r.Context().Err() != nil {
+				if fallback != nil {
+					return hedgeResponse(r, *fallback, metric), nil
+				}
+				return nil, lastErr
+			}
+			// 所有请求都失败, 立即发起下一次对冲
+			launch()
+			inflight++
+			if metric != nil {
+				metric.HedgeFired(r.URL)
+			}
+		}
+	}
+}
+
+// 返回胜出的响应, body关闭时取消请求context
+func hedgeResponse(r *http.Request, result hedgeResult, metric *Metric) *http.Response {
+	if result.index > 0 && metric != nil {
+		metric.HedgeWon(r.URL)
+	}
+	result.resp.Body = &cancelOnCloseBody{ReadCloser: result.resp.Body, cancel: result.cancel}
+
+	return result.resp
+}
+
+// 5xx、429可能是单个后端实例的问题, 不作为对冲结果
+func isHedgeRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// 关闭被取消请求的响应
+func discardHedgeResults(results <-chan hedgeResult, n int) {
+	for i := 0; i < n; i++ {
+		result := <-results
+		if result.resp != nil {
+			_ = result.resp.Body.Close()
+		}
+		result.cancel()
+	}
+}
+
+// body关闭时取消请求context
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.cancel)
+
+	return err
+}
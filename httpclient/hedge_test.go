@@ -0,0 +1,117 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_WithHedging(t *testing.T) {
+	var calls int32
+	var cancelled int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			select {
+			case <-req.Context().Done():
+				atomic.AddInt32(&cancelled, 1)
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		_, _ = io.WriteString(rw, "hedge")
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	m := NewMetric("hedge_test", "", nil)
+	EnableMetric(m)
+	defer func() {
+		metricValue = nil
+	}()
+
+	req := NewRequest(WithHedging(50*time.Millisecond, 2))
+	start := time.Now()
+	resp, err := req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "hedge", body)
+	require.True(t, time.Since(start) < time.Second)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&cancelled) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	u, _ := url.Parse(s.URL)
+	require.Equal(t, float64(1), testutil.ToFloat64(m.httpClientHedgeFiredTotal.WithLabelValues(u.Host, u.Path)))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.httpClientHedgeWonTotal.WithLabelValues(u.Host, u.Path)))
+}
+
+func TestRequest_WithHedgingSkipsPost(t *testing.T) {
+	var calls int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest(WithHedging(10*time.Millisecond, 3))
+	_, err := req.Post(s.URL, "name=golang", nil)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRequest_WithHedgingRetryableStatus(t *testing.T) {
+	var calls int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			// 第一次请求较慢但成功, 对冲请求快速返回503
+			time.Sleep(200 * time.Millisecond)
+			_, _ = io.WriteString(rw, "slow")
+			return
+		}
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest(WithHedging(50*time.Millisecond, 1))
+	resp, err := req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "slow", body)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// 所有请求都返回可重试状态码时返回该响应
+	s.Config.Handler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusTooManyRequests)
+	})
+	resp, err = req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, resp.Raw().StatusCode)
+}
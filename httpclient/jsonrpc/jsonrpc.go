@@ -0,0 +1,257 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package jsonrpc 基于httpclient.Request的JSON-RPC 2.0客户端
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/ouqiang/goutil/httpclient"
+)
+
+const version = "2.0"
+
+// 标准错误码
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// ErrMissingResponse 批量调用中服务端未返回对应id的响应
+var ErrMissingResponse = errors.New("jsonrpc: missing response")
+
+// Error JSON-RPC错误对象
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc: %s (code: %d)", e.Message, e.Code)
+}
+
+// HTTPError 响应不是有效的JSON-RPC响应
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("jsonrpc: unexpected http status %d: %s", e.StatusCode, e.Body)
+}
+
+type request struct {
+	Version string      `json:"jsonrpc"`
+	ID      *uint64     `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type response struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *Error          `json:"error"`
+}
+
+// BatchElem 批量调用中的单个请求
+type BatchElem struct {
+	Method string
+	// Params 位置参数使用slice, 命名参数使用map或结构体
+	Params interface{}
+	// Result 结果解码的目标, 为nil时忽略结果
+	Result interface{}
+	// Notify 为true时作为通知发送, 不等待响应
+	Notify bool
+	// Error 调用完成后设置, 服务端返回的错误为*Error
+	Error error
+}
+
+type options struct {
+	header http.Header
+}
+
+// Option 可选参数
+type Option func(*options)
+
+// WithHeader 每个请求附加的header, 如Authorization
+func WithHeader(header http.Header) Option {
+	return func(opt *options) {
+		opt.header = header
+	}
+}
+
+// Client JSON-RPC客户端, 并发安全
+type Client struct {
+	opts     options
+	endpoint string
+	req      *httpclient.Request
+	id       uint64
+}
+
+// NewClient 创建客户端, req为nil时使用默认配置
+func NewClient(endpoint string, req *httpclient.Request, opt ...Option) *Client {
+	c := &Client{
+		endpoint: endpoint,
+		req:      req,
+	}
+	for _, o := range opt {
+		o(&c.opts)
+	}
+	if c.req == nil {
+		c.req = httpclient.NewRequest()
+	}
+
+	return c
+}
+
+// Call 调用方法, 结果解码到result, 服务端返回错误时为*Error
+func (c *Client) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	id := c.nextID()
+	data, err := c.send(ctx, &request{Version: version, ID: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return ErrMissingResponse
+	}
+	resp := &response{}
+	if err = json.Unmarshal(data, resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	return decodeResult(resp.Result, result)
+}
+
+// Notify 发送通知, 服务端不返回结果
+func (c *Client) Notify(ctx context.Context, method string, params interface{}) error {
+	_, err := c.send(ctx, &request{Version: version, Method: method, Params: params})
+
+	return err
+}
+
+// BatchCall 批量调用, 返回的error仅表示请求失败, 单个调用的错误设置在BatchElem.Error
+func (c *Client) BatchCall(ctx context.Context, elems []*BatchElem) error {
+	if len(elems) == 0 {
+		return nil
+	}
+	requests := make([]*request, len(elems))
+	index := make(map[string]*BatchElem, len(elems))
+	for i, elem := range elems {
+		requests[i] = &request{Version: version, Method: elem.Method, Params: elem.Params}
+		if elem.Notify {
+			continue
+		}
+		id := c.nextID()
+		requests[i].ID = &id
+		index[strconv.FormatUint(id, 10)] = elem
+	}
+	data, err := c.send(ctx, requests)
+	if err != nil {
+		return err
+	}
+	if len(index) == 0 {
+		return nil
+	}
+
+	var responses []*response
+	if data == nil {
+		return ErrMissingResponse
+	}
+	if err = json.Unmarshal(data, &responses); err != nil {
+		// 整个批量请求无效时服务端返回单个错误对象
+		resp := &response{}
+		if json.Unmarshal(data, resp) == nil && resp.Error != nil {
+			return resp.Error
+		}
+		return err
+	}
+	for _, resp := range responses {
+		id := string(bytes.Trim(resp.ID, `"`))
+		elem, ok := index[id]
+		if !ok {
+			continue
+		}
+		delete(index, id)
+		if resp.Error != nil {
+			elem.Error = resp.Error
+			continue
+		}
+		elem.Error = decodeResult(resp.Result, elem.Result)
+	}
+	for _, elem := range index {
+		elem.Error = ErrMissingResponse
+	}
+
+	return nil
+}
+
+func (c *Client) nextID() uint64 {
+	return atomic.AddUint64(&c.id, 1)
+}
+
+func (c *Client) send(ctx context.Context, v interface{}) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	for k, v := range c.opts.header {
+		header[k] = append([]string(nil), v...)
+	}
+	header.Set("Content-Type", httpclient.MediaTypeJSON)
+	header.Set("Accept", httpclient.MediaTypeJSON)
+	resp, err := c.req.Do(ctx, http.MethodPost, c.endpoint, body, header)
+	if err != nil {
+		return nil, err
+	}
+	data, err := resp.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	// 通知没有响应体, 其余情况响应必须是JSON
+	data = bytes.TrimSpace(data)
+	statusCode := resp.Raw().StatusCode
+	if len(data) == 0 && statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices {
+		return nil, nil
+	}
+	if !json.Valid(data) {
+		return nil, &HTTPError{StatusCode: resp.Raw().StatusCode, Body: string(data)}
+	}
+
+	return data, nil
+}
+
+func decodeResult(raw json.RawMessage, result interface{}) error {
+	if result == nil || len(raw) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(raw, result)
+}
@@ -0,0 +1,149 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type rpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  []int           `json:"params"`
+}
+
+// 支持add、fail方法, 通知不返回响应
+type rpcServer struct {
+	mu       sync.Mutex
+	notified []string
+}
+
+func (s *rpcServer) handle(r *rpcRequest) interface{} {
+	if r.ID == nil {
+		s.mu.Lock()
+		s.notified = append(s.notified, r.Method)
+		s.mu.Unlock()
+		return nil
+	}
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": r.ID}
+	switch r.Method {
+	case "add":
+		sum := 0
+		for _, p := range r.Params {
+			sum += p
+		}
+		resp["result"] = sum
+	default:
+		resp["error"] = map[string]interface{}{"code": CodeMethodNotFound, "message": "method not found", "data": r.Method}
+	}
+
+	return resp
+}
+
+func (s *rpcServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	data, _ := ioutil.ReadAll(req.Body)
+	if len(data) > 0 && data[0] == '[' {
+		var requests []*rpcRequest
+		_ = json.Unmarshal(data, &requests)
+		var responses []interface{}
+		// 倒序返回, 验证按id匹配
+		for i := len(requests) - 1; i >= 0; i-- {
+			if resp := s.handle(requests[i]); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_ = json.NewEncoder(rw).Encode(responses)
+		return
+	}
+	r := &rpcRequest{}
+	_ = json.Unmarshal(data, r)
+	if resp := s.handle(r); resp != nil {
+		_ = json.NewEncoder(rw).Encode(resp)
+	}
+}
+
+func TestClient_Call(t *testing.T) {
+	rpc := &rpcServer{}
+	s := httptest.NewServer(rpc)
+	defer s.Close()
+
+	c := NewClient(s.URL, nil)
+	var sum int
+	require.NoError(t, c.Call(context.Background(), "add", []int{1, 2}, &sum))
+	require.Equal(t, 3, sum)
+
+	err := c.Call(context.Background(), "unknown", nil, &sum)
+	var rpcErr *Error
+	require.True(t, errors.As(err, &rpcErr))
+	require.Equal(t, CodeMethodNotFound, rpcErr.Code)
+	require.Equal(t, `"unknown"`, string(rpcErr.Data))
+
+	require.NoError(t, c.Notify(context.Background(), "ping", nil))
+	require.Equal(t, []string{"ping"}, rpc.notified)
+}
+
+func TestClient_BatchCall(t *testing.T) {
+	rpc := &rpcServer{}
+	s := httptest.NewServer(rpc)
+	defer s.Close()
+
+	c := NewClient(s.URL, nil)
+	var a, b int
+	elems := []*BatchElem{
+		{Method: "add", Params: []int{1, 2}, Result: &a},
+		{Method: "log", Notify: true},
+		{Method: "unknown"},
+		{Method: "add", Params: []int{3, 4}, Result: &b},
+	}
+	require.NoError(t, c.BatchCall(context.Background(), elems))
+	require.NoError(t, elems[0].Error)
+	require.NoError(t, elems[1].Error)
+	require.Error(t, elems[2].Error)
+	require.NoError(t, elems[3].Error)
+	require.Equal(t, 3, a)
+	require.Equal(t, 7, b)
+	require.Equal(t, []string{"log"}, rpc.notified)
+
+	require.NoError(t, c.BatchCall(context.Background(), []*BatchElem{{Method: "log", Notify: true}}))
+}
+
+func TestClient_HTTPError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusBadGateway)
+		_, _ = io.WriteString(rw, "bad gateway")
+	}))
+	defer s.Close()
+
+	c := NewClient(s.URL, nil)
+	err := c.Call(context.Background(), "add", nil, nil)
+	var httpErr *HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	require.Equal(t, http.StatusBadGateway, httpErr.StatusCode)
+}
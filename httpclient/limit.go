@@ -0,0 +1,129 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ResponseTooLargeError 响应body超过限制
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("httpclient: response body exceeds %d bytes", e.Limit)
+}
+
+// BodyReadTimeoutError 读取响应body空闲超时
+type BodyReadTimeoutError struct {
+	Idle time.Duration
+}
+
+func (e *BodyReadTimeoutError) Error() string {
+	return fmt.Sprintf("httpclient: no data received from response body in %s", e.Idle)
+}
+
+// Timeout 实现net.Error
+func (e *BodyReadTimeoutError) Timeout() bool {
+	return true
+}
+
+// Temporary 实现net.Error
+func (e *BodyReadTimeoutError) Temporary() bool {
+	return true
+}
+
+// WithMaxResponseBytes 限制Bytes、String、DecodeJSON、DecodeProtoBuf读取的body大小, 超过时返回*ResponseTooLargeError
+func WithMaxResponseBytes(n int64) Option {
+	return func(opt *options) {
+		opt.maxResponseBytes = n
+	}
+}
+
+// WithMaxDownloadBytes 限制WriteFile、WriteTo写入的字节数, 超过时返回*ResponseTooLargeError, WriteFile会删除已写入的文件
+func WithMaxDownloadBytes(n int64) Option {
+	return func(opt *options) {
+		opt.maxDownloadBytes = n
+	}
+}
+
+// WithBodyReadTimeout 读取响应body时, 超过d未收到数据则中断, 返回*BodyReadTimeoutError
+func WithBodyReadTimeout(d time.Duration) Option {
+	return func(opt *options) {
+		opt.bodyReadTimeout = d
+	}
+}
+
+// 读取body超过n字节时返回*ResponseTooLargeError
+func limitReader(r io.Reader, n int64) io.Reader {
+	if n <= 0 {
+		return r
+	}
+
+	return &limitedReader{r: r, n: n, err: &ResponseTooLargeError{Limit: n}}
+}
+
+// 单次Read超时后取消请求context, 使阻塞的Read返回
+type idleTimeoutBody struct {
+	io.ReadCloser
+	timeout  time.Duration
+	timer    *time.Timer
+	cancel   context.CancelFunc
+	timedOut int32
+	once     sync.Once
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) *idleTimeoutBody {
+	b := &idleTimeoutBody{
+		ReadCloser: body,
+		timeout:    timeout,
+		cancel:     cancel,
+	}
+	b.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&b.timedOut, 1)
+		cancel()
+	})
+	b.timer.Stop()
+
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&b.timedOut) == 1 {
+		return 0, &BodyReadTimeoutError{Idle: b.timeout}
+	}
+	b.timer.Reset(b.timeout)
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Stop()
+	if atomic.LoadInt32(&b.timedOut) == 1 {
+		return n, &BodyReadTimeoutError{Idle: b.timeout}
+	}
+
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.once.Do(b.cancel)
+
+	return err
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_WithMaxResponseBytes(t *testing.T) {
+	content := `"` + strings.Repeat("a", 1022) + `"`
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(rw, content)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest(WithMaxResponseBytes(100), WithMaxDownloadBytes(512))
+	resp, err := req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	_, err = resp.String()
+	var tooLarge *ResponseTooLargeError
+	require.True(t, errors.As(err, &tooLarge))
+	require.Equal(t, int64(100), tooLarge.Limit)
+
+	resp, err = req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	var v interface{}
+	require.True(t, errors.As(resp.DecodeJSON(&v), &tooLarge))
+
+	resp, err = req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	buf := new(bytes.Buffer)
+	n, err := resp.WriteTo(buf)
+	require.True(t, errors.As(err, &tooLarge))
+	require.Equal(t, int64(512), tooLarge.Limit)
+	require.Equal(t, int64(512), n)
+
+	tmpFile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	_ = tmpFile.Close()
+	resp, err = req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	_, err = resp.WriteFile(tmpFile.Name())
+	require.True(t, errors.As(err, &tooLarge))
+	_, err = os.Stat(tmpFile.Name())
+	require.True(t, os.IsNotExist(err))
+
+	req = NewRequest(WithMaxResponseBytes(1024))
+	resp, err = req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, content, body)
+}
+
+func TestRequest_WithBodyReadTimeout(t *testing.T) {
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(rw, "partial")
+		rw.(http.Flusher).Flush()
+		select {
+		case <-req.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest(WithBodyReadTimeout(100 * time.Millisecond))
+	resp, err := req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = resp.Bytes()
+	var timeout *BodyReadTimeoutError
+	require.True(t, errors.As(err, &timeout))
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr))
+	require.True(t, netErr.Timeout())
+	require.True(t, time.Since(start) < time.Second)
+}
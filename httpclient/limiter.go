@@ -0,0 +1,299 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LimitOutcome 请求结果, 用于调整并发上限
+type LimitOutcome int
+
+const (
+	// LimitSuccess 请求成功, 记录延迟
+	LimitSuccess LimitOutcome = iota
+	// LimitDropped 超时、429、503等过载信号
+	LimitDropped
+	// LimitIgnored 与容量无关的结果, 如调用方取消, 不调整上限
+	LimitIgnored
+)
+
+// LimitAlgorithm 并发上限调整算法
+type LimitAlgorithm interface {
+	// Update 根据一次请求结果返回新的上限, inFlight为请求开始时的并发数
+	Update(limit float64, rtt time.Duration, inFlight int, dropped bool) float64
+}
+
+// AIMD 加性增乘性减, 成功且并发接近上限时加1, 过载时乘以backoff(0~1)
+func AIMD(backoff float64) LimitAlgorithm {
+	if backoff <= 0 || backoff >= 1 {
+		backoff = 0.9
+	}
+
+	return &aimd{backoff: backoff}
+}
+
+type aimd struct {
+	backoff float64
+}
+
+func (a *aimd) Update(limit float64, rtt time.Duration, inFlight int, dropped bool) float64 {
+	if dropped {
+		return limit * a.backoff
+	}
+	// 并发未达到上限一半时说明上限不是瓶颈, 不增加
+	if float64(inFlight)*2 >= limit {
+		return limit + 1
+	}
+
+	return limit
+}
+
+// Vegas 基于延迟的算法, 以最小延迟估算排队数量, 排队少时增加上限, 排队多或过载时减少
+func Vegas() LimitAlgorithm {
+	return &vegas{}
+}
+
+type vegas struct {
+	mu     sync.Mutex
+	minRTT time.Duration
+	// 定期重置最小延迟, 适应服务端容量变化
+	samples int
+}
+
+const vegasProbeSamples = 1000
+
+func (v *vegas) Update(limit float64, rtt time.Duration, inFlight int, dropped bool) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.samples++
+	if v.samples >= vegasProbeSamples {
+		v.samples = 0
+		v.minRTT = 0
+	}
+	if rtt > 0 && (v.minRTT == 0 || rtt < v.minRTT) {
+		v.minRTT = rtt
+	}
+	step := math.Max(1, math.Log10(limit))
+	if dropped {
+		return limit - step
+	}
+	if float64(inFlight)*2 < limit || v.minRTT == 0 || rtt <= 0 {
+		return limit
+	}
+
+	queue := math.Ceil(limit * (1 - float64(v.minRTT)/float64(rtt)))
+	alpha, beta := 3*step, 6*step
+	switch {
+	case queue <= step:
+		return limit + beta
+	case queue < alpha:
+		return limit + step
+	case queue > beta:
+		return limit - step
+	}
+
+	return limit
+}
+
+type limiterOptions struct {
+	name         string
+	initialLimit int
+	minLimit     int
+	maxLimit     int
+}
+
+// LimiterOption 并发限制可选参数
+type LimiterOption func(*limiterOptions)
+
+// WithLimiterName 名称, 作为metric的limiter标签
+func WithLimiterName(name string) LimiterOption {
+	return func(opt *limiterOptions) {
+		opt.name = name
+	}
+}
+
+// WithLimitRange 初始值和上下限, 默认20, [1, 1000]
+func WithLimitRange(initial, min, max int) LimiterOption {
+	return func(opt *limiterOptions) {
+		opt.initialLimit = initial
+		opt.minLimit = min
+		opt.maxLimit = max
+	}
+}
+
+// AdaptiveLimiter 自适应并发限制, 达到上限时请求等待, 并发安全
+type AdaptiveLimiter struct {
+	opts      limiterOptions
+	algorithm LimitAlgorithm
+	mu        sync.Mutex
+	limit     float64
+	inFlight  int
+	waiters   []chan struct{}
+}
+
+// NewAdaptiveLimiter 创建自适应并发限制, 通过WithAdaptiveLimiter启用
+func NewAdaptiveLimiter(algorithm LimitAlgorithm, opt ...LimiterOption) *AdaptiveLimiter {
+	l := &AdaptiveLimiter{
+		algorithm: algorithm,
+		opts: limiterOptions{
+			initialLimit: 20,
+			minLimit:     1,
+			maxLimit:     1000,
+		},
+	}
+	for _, o := range opt {
+		o(&l.opts)
+	}
+	if l.opts.minLimit < 1 {
+		l.opts.minLimit = 1
+	}
+	if l.opts.maxLimit < l.opts.minLimit {
+		l.opts.maxLimit = l.opts.minLimit
+	}
+	l.limit = l.clamp(float64(l.opts.initialLimit))
+	l.report()
+
+	return l
+}
+
+// WithAdaptiveLimiter 限制并发请求数, 每次重试单独计数
+func WithAdaptiveLimiter(l *AdaptiveLimiter) Option {
+	return func(opt *options) {
+		opt.limiter = l
+	}
+}
+
+// Limit 当前并发上限
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit)
+}
+
+// InFlight 当前并发数
+func (l *AdaptiveLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.inFlight
+}
+
+// Acquire 获取执行许可, 请求完成后调用release报告结果
+func (l *AdaptiveLimiter) Acquire(ctx context.Context) (release func(LimitOutcome), err error) {
+	l.mu.Lock()
+	for l.inFlight >= int(l.limit) {
+		ch := make(chan struct{})
+		l.waiters = append(l.waiters, ch)
+		l.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			l.mu.Lock()
+			l.removeWaiter(ch)
+			l.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		l.mu.Lock()
+	}
+	l.inFlight++
+	inFlight := l.inFlight
+	l.mu.Unlock()
+
+	start := time.Now()
+	var once sync.Once
+	release = func(outcome LimitOutcome) {
+		once.Do(func() {
+			l.release(outcome, time.Since(start), inFlight)
+		})
+	}
+
+	return release, nil
+}
+
+func (l *AdaptiveLimiter) release(outcome LimitOutcome, rtt time.Duration, inFlight int) {
+	l.mu.Lock()
+	l.inFlight--
+	changed := false
+	if outcome != LimitIgnored {
+		limit := l.clamp(l.algorithm.Update(l.limit, rtt, inFlight, outcome == LimitDropped))
+		changed = int(limit) != int(l.limit)
+		l.limit = limit
+	}
+	l.wakeLocked()
+	l.mu.Unlock()
+	if changed {
+		l.report()
+	}
+}
+
+// wakeLocked 按空闲数量唤醒等待者, 被唤醒后重新检查, 调用方需持有锁
+func (l *AdaptiveLimiter) wakeLocked() {
+	for n := int(l.limit) - l.inFlight; n > 0 && len(l.waiters) > 0; n-- {
+		close(l.waiters[0])
+		l.waiters = l.waiters[1:]
+	}
+}
+
+func (l *AdaptiveLimiter) removeWaiter(ch chan struct{}) {
+	for i, w := range l.waiters {
+		if w == ch {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return
+		}
+	}
+	// 已被唤醒但放弃执行, 转交给其他等待者
+	l.wakeLocked()
+}
+
+func (l *AdaptiveLimiter) clamp(limit float64) float64 {
+	return math.Min(math.Max(limit, float64(l.opts.minLimit)), float64(l.opts.maxLimit))
+}
+
+func (l *AdaptiveLimiter) report() {
+	if metricValue == nil {
+		return
+	}
+	if metric, _ := metricValue.Load().(*Metric); metric != nil {
+		metric.ConcurrencyLimit(l.opts.name, float64(l.Limit()))
+	}
+}
+
+// limitOutcome 超时、429、503视为过载, 调用方取消和其他错误不调整上限
+func limitOutcome(resp *http.Response, err error) LimitOutcome {
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return LimitIgnored
+		}
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+			return LimitDropped
+		}
+		return LimitIgnored
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return LimitDropped
+	}
+
+	return LimitSuccess
+}
@@ -0,0 +1,117 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAIMD(t *testing.T) {
+	a := AIMD(0.5)
+	require.Equal(t, 11.0, a.Update(10, time.Millisecond, 5, false))
+	require.Equal(t, 10.0, a.Update(10, time.Millisecond, 4, false))
+	require.Equal(t, 5.0, a.Update(10, time.Millisecond, 10, true))
+}
+
+func TestVegas(t *testing.T) {
+	v := Vegas()
+	// 延迟稳定时增加
+	limit := v.Update(10, 10*time.Millisecond, 10, false)
+	require.True(t, limit > 10)
+	// 延迟翻倍说明排队严重, 减少
+	require.True(t, v.Update(limit, 20*time.Millisecond, int(limit), false) < limit)
+	require.True(t, v.Update(limit, 10*time.Millisecond, int(limit), true) < limit)
+	// 并发远低于上限时不调整
+	require.Equal(t, limit, v.Update(limit, 10*time.Millisecond, 1, false))
+}
+
+func TestAdaptiveLimiter_Acquire(t *testing.T) {
+	l := NewAdaptiveLimiter(AIMD(0.5), WithLimitRange(2, 1, 4))
+	release1, err := l.Acquire(context.Background())
+	require.NoError(t, err)
+	release2, err := l.Acquire(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, l.InFlight())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = l.Acquire(ctx)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	acquired := make(chan struct{})
+	go func() {
+		release, err := l.Acquire(context.Background())
+		if err == nil {
+			release(LimitIgnored)
+		}
+		close(acquired)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	release1(LimitSuccess)
+	<-acquired
+	require.Equal(t, 3, l.Limit())
+
+	release2(LimitDropped)
+	release2(LimitDropped)
+	require.Equal(t, 1, l.Limit())
+	require.Equal(t, 0, l.InFlight())
+}
+
+func TestRequest_WithAdaptiveLimiter(t *testing.T) {
+	var inFlight, maxInFlight, requests int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		if atomic.AddInt32(&requests, 1)%2 == 0 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	l := NewAdaptiveLimiter(AIMD(0.5), WithLimitRange(8, 2, 8), WithLimiterName("test"))
+	req := NewRequest(WithAdaptiveLimiter(l))
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := req.Get(s.URL, nil, nil)
+			if err == nil {
+				_, _ = resp.Bytes()
+			}
+		}()
+	}
+	wg.Wait()
+	require.True(t, atomic.LoadInt32(&maxInFlight) <= 8)
+	require.True(t, l.Limit() < 8)
+	require.Equal(t, 0, l.InFlight())
+}
@@ -16,6 +16,9 @@ type Metric struct {
 	formatUrl                        FormatUrl
 	httpClientRequestTotal           *prometheus.CounterVec
 	httpClientRequestDurationSeconds *prometheus.HistogramVec
+	httpClientHedgeFiredTotal        *prometheus.CounterVec
+	httpClientHedgeWonTotal          *prometheus.CounterVec
+	httpClientConcurrencyLimit       *prometheus.GaugeVec
 }
 
 type FormatUrl func(u *url.URL)
@@ -45,6 +48,27 @@ func NewMetric(namespace, subsystem string, formatUrl FormatUrl) *Metric {
 	}, []string{"host", "path", "status"})
 	prometheus.MustRegister(m.httpClientRequestTotal)
 
+	m.httpClientHedgeFiredTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_client_hedge_fired_total",
+		Help:      "http client hedged requests fired total",
+	}, []string{"host", "path"})
+	prometheus.MustRegister(m.httpClientHedgeFiredTotal)
+
+	m.httpClientHedgeWonTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_client_hedge_won_total",
+		Help:      "http client hedged requests won total",
+	}, []string{"host", "path"})
+	prometheus.MustRegister(m.httpClientHedgeWonTotal)
+
+	m.httpClientConcurrencyLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_client_concurrency_limit",
+		Help:      "http client adaptive concurrency limit",
+	}, []string{"limiter"})
+	prometheus.MustRegister(m.httpClientConcurrencyLimit)
+
 	return m
 }
 
@@ -68,3 +92,84 @@ func (m *Metric) Latency(url *url.URL, d time.Duration) {
 
 	m.httpClientRequestDurationSeconds.WithLabelValues(url.Host, url.Path).Observe(d.Seconds())
 }
+
+// HedgeFired 发起对冲请求
+func (m *Metric) HedgeFired(url *url.URL) {
+	u := *url
+	m.formatUrl(&u)
+
+	m.httpClientHedgeFiredTotal.WithLabelValues(u.Host, u.Path).Inc()
+}
+
+// HedgeWon 对冲请求先于原始请求返回
+func (m *Metric) HedgeWon(url *url.URL) {
+	u := *url
+	m.formatUrl(&u)
+
+	m.httpClientHedgeWonTotal.WithLabelValues(u.Host, u.Path).Inc()
+}
+
+// ConcurrencyLimit 自适应并发上限
+func (m *Metric) ConcurrencyLimit(limiter string, limit float64) {
+	m.httpClientConcurrencyLimit.WithLabelValues(limiter).Set(limit)
+}
+
+// RegisterStats 注册Request连接池统计, client作为标签区分多个Request
+func (m *Metric) RegisterStats(client string, req *Request) {
+	prometheus.MustRegister(newStatsCollector(m.namespace, client, req))
+}
+
+// statsCollector 采集时读取连接池统计快照
+type statsCollector struct {
+	client             string
+	req                *Request
+	conns              *prometheus.Desc
+	gotConns           *prometheus.Desc
+	reusedConns        *prometheus.Desc
+	dials              *prometheus.Desc
+	dialErrors         *prometheus.Desc
+	tlsHandshakes      *prometheus.Desc
+	tlsHandshakeErrors *prometheus.Desc
+}
+
+func newStatsCollector(namespace, client string, req *Request) *statsCollector {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help,
+			append([]string{"host"}, labels...), prometheus.Labels{"client": client})
+	}
+
+	return &statsCollector{
+		client:             client,
+		req:                req,
+		conns:              desc("http_client_connections", "http client open connections", "state"),
+		gotConns:           desc("http_client_got_conn_total", "http client connections obtained total"),
+		reusedConns:        desc("http_client_reused_conn_total", "http client reused connections total"),
+		dials:              desc("http_client_dial_total", "http client dial total"),
+		dialErrors:         desc("http_client_dial_errors_total", "http client dial errors total"),
+		tlsHandshakes:      desc("http_client_tls_handshake_total", "http client tls handshake total"),
+		tlsHandshakeErrors: desc("http_client_tls_handshake_errors_total", "http client tls handshake errors total"),
+	}
+}
+
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.conns
+	ch <- c.gotConns
+	ch <- c.reusedConns
+	ch <- c.dials
+	ch <- c.dialErrors
+	ch <- c.tlsHandshakes
+	ch <- c.tlsHandshakeErrors
+}
+
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, h := range c.req.Stats().Hosts {
+		ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(h.IdleConns), h.Host, "idle")
+		ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(h.ActiveConns), h.Host, "active")
+		ch <- prometheus.MustNewConstMetric(c.gotConns, prometheus.CounterValue, float64(h.GotConns), h.Host)
+		ch <- prometheus.MustNewConstMetric(c.reusedConns, prometheus.CounterValue, float64(h.ReusedConns), h.Host)
+		ch <- prometheus.MustNewConstMetric(c.dials, prometheus.CounterValue, float64(h.Dials), h.Host)
+		ch <- prometheus.MustNewConstMetric(c.dialErrors, prometheus.CounterValue, float64(h.DialErrors), h.Host)
+		ch <- prometheus.MustNewConstMetric(c.tlsHandshakes, prometheus.CounterValue, float64(h.TLSHandshakes), h.Host)
+		ch <- prometheus.MustNewConstMetric(c.tlsHandshakeErrors, prometheus.CounterValue, float64(h.TLSHandshakeErrors), h.Host)
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package mock 可编程的http.RoundTripper, 用于单元测试, 不建立网络连接
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// ResponderFunc 根据请求生成响应
+type ResponderFunc func(req *http.Request) (*http.Response, error)
+
+// NoResponderError 请求没有匹配的响应
+type NoResponderError struct {
+	Method string
+	URL    string
+}
+
+func (e *NoResponderError) Error() string {
+	return fmt.Sprintf("mock: no responder for %s %s", e.Method, e.URL)
+}
+
+// TestingT testing.T的子集
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// Transport 实现http.RoundTripper
+type Transport struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	calls        int
+}
+
+// NewTransport 创建Transport
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// On 注册响应, pattern包含"://"时匹配完整URL(不含query), 否则匹配path, 支持path.Match通配符
+func (t *Transport) On(method, pattern string) *Expectation {
+	e := &Expectation{
+		method:  strings.ToUpper(method),
+		pattern: pattern,
+	}
+	t.add(e)
+
+	return e
+}
+
+// OnRegexp 注册响应, 正则匹配完整URL
+func (t *Transport) OnRegexp(method string, re *regexp.Regexp) *Expectation {
+	e := &Expectation{
+		method: strings.ToUpper(method),
+		re:     re,
+	}
+	t.add(e)
+
+	return e
+}
+
+func (t *Transport) add(e *Expectation) {
+	e.status = http.StatusOK
+	e.header = make(http.Header)
+	e.times = -1
+	e.transport = t
+	t.mu.Lock()
+	t.expectations = append(t.expectations, e)
+	t.mu.Unlock()
+}
+
+// RoundTrip 实现http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.calls++
+	var matched *Expectation
+	for _, e := range t.expectations {
+		if e.exhausted() || !e.match(req) {
+			continue
+		}
+		e.calls++
+		matched = e
+		break
+	}
+	t.mu.Unlock()
+
+	if req.Body != nil {
+		_, _ = ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+	}
+	if matched == nil {
+		return nil, &NoResponderError{
+			Method: req.Method,
+			URL:    req.URL.String(),
+		}
+	}
+
+	return matched.respond(req)
+}
+
+// Calls 总请求次数
+func (t *Transport) Calls() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.calls
+}
+
+// ExpectationsWereMet 检查所有响应是否被调用了指定次数, 未设置次数的至少调用一次
+func (t *Transport) ExpectationsWereMet() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var errs []string
+	for _, e := range t.expectations {
+		if e.times < 0 && e.calls == 0 {
+			errs = append(errs, fmt.Sprintf("%s was not called", e))
+		}
+		if e.times >= 0 && e.calls != e.times {
+			errs = append(errs, fmt.Sprintf("%s expected %d calls, got %d", e, e.times, e.calls))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("mock: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// AssertExpectations 断言所有响应都被调用
+func (t *Transport) AssertExpectations(tt TestingT) bool {
+	if err := t.ExpectationsWereMet(); err != nil {
+		tt.Errorf("%s", err)
+		return false
+	}
+
+	return true
+}
+
+// Reset 清空注册的响应和调用记录
+func (t *Transport) Reset() {
+	t.mu.Lock()
+	t.expectations = nil
+	t.calls = 0
+	t.mu.Unlock()
+}
+
+// Expectation 注册的响应
+type Expectation struct {
+	method    string
+	pattern   string
+	re        *regexp.Regexp
+	status    int
+	header    http.Header
+	body      []byte
+	err       error
+	responder ResponderFunc
+	delay     time.Duration
+	times     int
+	// calls 由transport.mu保护
+	calls     int
+	transport *Transport
+}
+
+// Reply 返回指定状态码和body
+func (e *Expectation) Reply(status int, body string) *Expectation {
+	e.status = status
+	e.body = []byte(body)
+
+	return e
+}
+
+// ReplyJSON 返回json body
+func (e *Expectation) ReplyJSON(status int, v interface{}) *Expectation {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	e.status = status
+	e.body = data
+	e.header.Set("Content-Type", "application/json")
+
+	return e
+}
+
+// ReplyProtoBuf 返回protoBuf body
+func (e *Expectation) ReplyProtoBuf(status int, v proto.Message) *Expectation {
+	data, err := proto.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	e.status = status
+	e.body = data
+	e.header.Set("Content-Type", "application/x-protobuf")
+
+	return e
+}
+
+// ReplyError 返回错误, 模拟网络异常
+func (e *Expectation) ReplyError(err error) *Expectation {
+	e.err = err
+
+	return e
+}
+
+// ReplyFunc 自定义响应
+func (e *Expectation) ReplyFunc(f ResponderFunc) *Expectation {
+	e.responder = f
+
+	return e
+}
+
+// Header 设置响应header
+func (e *Expectation) Header(key, value string) *Expectation {
+	e.header.Add(key, value)
+
+	return e
+}
+
+// Delay 模拟延迟, 请求context取消时提前返回
+func (e *Expectation) Delay(d time.Duration) *Expectation {
+	e.delay = d
+
+	return e
+}
+
+// Times 匹配次数, 超过后不再匹配, 可用于模拟重试
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+
+	return e
+}
+
+// Once 只匹配一次
+func (e *Expectation) Once() *Expectation {
+	return e.Times(1)
+}
+
+// Calls 被调用次数
+func (e *Expectation) Calls() int {
+	e.transport.mu.Lock()
+	defer e.transport.mu.Unlock()
+
+	return e.calls
+}
+
+func (e *Expectation) String() string {
+	if e.re != nil {
+		return fmt.Sprintf("%s %s", e.method, e.re)
+	}
+
+	return fmt.Sprintf("%s %s", e.method, e.pattern)
+}
+
+func (e *Expectation) exhausted() bool {
+	return e.times >= 0 && e.calls >= e.times
+}
+
+func (e *Expectation) match(req *http.Request) bool {
+	if e.method != "" && e.method != req.Method {
+		return false
+	}
+	if e.re != nil {
+		return e.re.MatchString(req.URL.String())
+	}
+	target := req.URL.Path
+	if strings.Contains(e.pattern, "://") {
+		u := *req.URL
+		u.RawQuery = ""
+		u.Fragment = ""
+		target = u.String()
+	}
+	ok, err := path.Match(e.pattern, target)
+
+	return err == nil && ok
+}
+
+func (e *Expectation) respond(req *http.Request) (*http.Response, error) {
+	if e.delay > 0 {
+		timer := time.NewTimer(e.delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	if e.responder != nil {
+		return e.responder(req)
+	}
+
+	return NewResponse(req, e.status, e.header.Clone(), e.body), nil
+}
+
+// NewResponse 创建http.Response
+func NewResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package mock
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ouqiang/goutil/httpclient"
+)
+
+func TestTransport_Reply(t *testing.T) {
+	tr := NewTransport()
+	tr.On(http.MethodGet, "/users/*").ReplyJSON(http.StatusOK, map[string]string{"name": "golang"})
+	tr.On(http.MethodPost, "https://example.com/users").Reply(http.StatusCreated, "created").Header("X-Id", "1")
+
+	req := httpclient.NewRequest(httpclient.WithTransport(tr))
+	resp, err := req.Get("https://example.com/users/1", nil, nil)
+	require.NoError(t, err)
+	var user struct {
+		Name string
+	}
+	require.NoError(t, resp.DecodeJSON(&user))
+	require.Equal(t, "golang", user.Name)
+
+	resp, err = req.Post("https://example.com/users?debug=1", "name=golang", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.Raw().StatusCode)
+	require.Equal(t, "1", resp.Header().Get("X-Id"))
+
+	_, err = req.Get("https://example.com/orders", nil, nil)
+	var noResponder *NoResponderError
+	require.True(t, errors.As(err, &noResponder))
+	require.Equal(t, 3, tr.Calls())
+	require.NoError(t, tr.ExpectationsWereMet())
+}
+
+func TestTransport_Retry(t *testing.T) {
+	tr := NewTransport()
+	failure := tr.On(http.MethodGet, "/retry").ReplyError(errors.New("connection reset")).Once()
+	success := tr.OnRegexp(http.MethodGet, regexp.MustCompile(`/retry$`)).Reply(http.StatusOK, "ok").Once()
+
+	intercepted := 0
+	req := httpclient.NewRequest(
+		httpclient.WithTransport(tr),
+		httpclient.WithRetryTime(1),
+		httpclient.WithRequestInterceptor(func(r *http.Request) {
+			intercepted++
+		}),
+	)
+	resp, err := req.Get("http://example.com/retry", nil, nil)
+	require.NoError(t, err)
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "ok", body)
+	require.Equal(t, 1, failure.Calls())
+	require.Equal(t, 1, success.Calls())
+	require.Equal(t, 2, intercepted)
+	require.True(t, tr.AssertExpectations(t))
+
+	tr.On(http.MethodGet, "/never").Times(2)
+	require.Error(t, tr.ExpectationsWereMet())
+}
+
+func TestTransport_Delay(t *testing.T) {
+	tr := NewTransport()
+	tr.On(http.MethodGet, "/slow").Delay(time.Second)
+
+	req := httpclient.NewRequest(httpclient.WithTransport(tr))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := req.Do(ctx, http.MethodGet, "http://example.com/slow", nil, nil)
+	require.Error(t, err)
+	require.True(t, time.Since(start) < time.Second)
+}
+
+func TestExpectation_CallsConcurrent(t *testing.T) {
+	tr := NewTransport()
+	e := tr.On(http.MethodGet, "/users").Reply(http.StatusOK, "ok")
+
+	req := httpclient.NewRequest(httpclient.WithTransport(tr))
+	errs := make(chan error, 10)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := req.Get("http://example.com/users", nil, nil)
+			if err == nil {
+				_, err = resp.String()
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		_ = e.Calls()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, 10, e.Calls())
+}
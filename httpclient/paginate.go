@@ -0,0 +1,475 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrMaxPagesReached 达到最大页数时仍有下一页
+var ErrMaxPagesReached = errors.New("httpclient: max pages reached")
+
+// PageStatusError 分页请求返回非2xx状态码
+type PageStatusError struct {
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *PageStatusError) Error() string {
+	return fmt.Sprintf("httpclient: paginate %s: unexpected status %d", e.URL, e.StatusCode)
+}
+
+// Page 已获取的一页
+type Page struct {
+	// Index 页序号, 从0开始
+	Index    int
+	URL      *url.URL
+	Response *http.Response
+	// Body 响应body, Response.Body已读取
+	Body  []byte
+	Items []json.RawMessage
+}
+
+// PageStrategy 分页方式
+type PageStrategy interface {
+	// NextURL 根据当前页返回下一页url, ok为false表示已是最后一页
+	NextURL(page *Page) (next string, ok bool, err error)
+}
+
+// IndexedPageStrategy 可根据页序号直接计算url的分页方式, 支持并发获取
+type IndexedPageStrategy interface {
+	PageStrategy
+	PageURL(base *url.URL, index int) string
+}
+
+// LinkHeader 按响应头Link: <url>; rel="next"翻页
+func LinkHeader() PageStrategy {
+	return linkHeaderStrategy{}
+}
+
+type linkHeaderStrategy struct{}
+
+func (linkHeaderStrategy) NextURL(page *Page) (string, bool, error) {
+	for _, link := range parseLinkHeader(page.Response.Header["Link"]) {
+		if link.rel != "next" {
+			continue
+		}
+		u, err := page.URL.Parse(link.url)
+		if err != nil {
+			return "", false, err
+		}
+		return u.String(), true, nil
+	}
+
+	return "", false, nil
+}
+
+// Cursor 从响应中按JSON pointer(RFC 6901)读取游标, 作为param参数请求下一页, 游标为空时结束
+//
+//	Cursor("/meta/next_cursor", "cursor")
+func Cursor(pointer string, param string) PageStrategy {
+	return cursorStrategy{pointer: pointer, param: param}
+}
+
+type cursorStrategy struct {
+	pointer string
+	param   string
+}
+
+func (s cursorStrategy) NextURL(page *Page) (string, bool, error) {
+	raw, ok, err := lookupJSONPointer(page.Body, s.pointer)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	var cursor string
+	var v interface{}
+	if err = json.Unmarshal(raw, &v); err != nil {
+		return "", false, err
+	}
+	switch value := v.(type) {
+	case nil:
+		return "", false, nil
+	case string:
+		cursor = value
+	case float64, bool:
+		cursor = string(bytes.TrimSpace(raw))
+	default:
+		return "", false, fmt.Errorf("httpclient: cursor %s is not a scalar", s.pointer)
+	}
+	if cursor == "" {
+		return "", false, nil
+	}
+
+	return setQueryParam(page.URL, s.param, cursor), true, nil
+}
+
+// PageNumber 页码分页, 第一页为start, 返回空列表时结束
+func PageNumber(param string, start int) IndexedPageStrategy {
+	return pageNumberStrategy{param: param, start: start}
+}
+
+type pageNumberStrategy struct {
+	param string
+	start int
+}
+
+func (s pageNumberStrategy) PageURL(base *url.URL, index int) string {
+	return setQueryParam(base, s.param, strconv.Itoa(s.start+index))
+}
+
+func (s pageNumberStrategy) NextURL(page *Page) (string, bool, error) {
+	if len(page.Items) == 0 {
+		return "", false, nil
+	}
+
+	return s.PageURL(page.URL, page.Index+1), true, nil
+}
+
+// Offset 偏移量分页, 返回的数量小于pageSize时结束, 每页数量参数需在url中自行设置
+// pageSize必须大于0, 否则Paginator.Err返回错误
+func Offset(param string, pageSize int) IndexedPageStrategy {
+	return offsetStrategy{param: param, pageSize: pageSize}
+}
+
+type offsetStrategy struct {
+	param    string
+	pageSize int
+}
+
+func (s offsetStrategy) PageURL(base *url.URL, index int) string {
+	return setQueryParam(base, s.param, strconv.Itoa(index*s.pageSize))
+}
+
+func (s offsetStrategy) NextURL(page *Page) (string, bool, error) {
+	if len(page.Items) == 0 || len(page.Items) < s.pageSize {
+		return "", false, nil
+	}
+
+	return s.PageURL(page.URL, page.Index+1), true, nil
+}
+
+type paginatorOptions struct {
+	itemsPointer string
+	concurrency  int
+	maxPages     int
+}
+
+// PaginatorOption 分页可选参数
+type PaginatorOption func(*paginatorOptions)
+
+// WithItemsPointer 列表在响应中的JSON pointer, 如"/data", 默认响应本身为数组
+func WithItemsPointer(pointer string) PaginatorOption {
+	return func(opt *paginatorOptions) {
+		opt.itemsPointer = pointer
+	}
+}
+
+// WithPageConcurrency 并发获取的页数, 仅对IndexedPageStrategy生效
+func WithPageConcurrency(n int) PaginatorOption {
+	return func(opt *paginatorOptions) {
+		opt.concurrency = n
+	}
+}
+
+// WithMaxPages 最多获取的页数, 超过后Err返回ErrMaxPagesReached
+func WithMaxPages(n int) PaginatorOption {
+	return func(opt *paginatorOptions) {
+		opt.maxPages = n
+	}
+}
+
+// Paginator 分页迭代器, 不能并发使用
+//
+//	p := req.Paginate(url, nil, httpclient.LinkHeader())
+//	for p.Next(ctx) {
+//		item := &Item{}
+//		if err := p.Decode(item); err != nil {
+//			return err
+//		}
+//	}
+//	if err := p.Err(); err != nil {
+//		return err
+//	}
+type Paginator struct {
+	req      *Request
+	header   http.Header
+	strategy PageStrategy
+	opts     paginatorOptions
+	base     *url.URL
+	nextURL  string
+	index    int
+	done     bool
+	items    []json.RawMessage
+	pos      int
+	current  json.RawMessage
+	err      error
+}
+
+// Paginate 创建分页迭代器, url为第一页地址
+func (req *Request) Paginate(rawURL string, header http.Header, strategy PageStrategy, opt ...PaginatorOption) *Paginator {
+	p := &Paginator{
+		req:      req,
+		header:   header,
+		strategy: strategy,
+		nextURL:  rawURL,
+	}
+	for _, o := range opt {
+		o(&p.opts)
+	}
+	if p.opts.concurrency <= 0 {
+		p.opts.concurrency = 1
+	}
+	p.base, p.err = url.Parse(rawURL)
+	if s, ok := strategy.(offsetStrategy); ok && s.pageSize <= 0 && p.err == nil {
+		p.err = fmt.Errorf("httpclient: invalid offset page size %d", s.pageSize)
+	}
+	if indexed, ok := strategy.(IndexedPageStrategy); ok && p.err == nil {
+		p.nextURL = indexed.PageURL(p.base, 0)
+	}
+
+	return p
+}
+
+// Next 移动到下一项, 没有更多数据或出错时返回false
+func (p *Paginator) Next(ctx context.Context) bool {
+	for p.pos >= len(p.items) {
+		if p.err != nil || p.done {
+			return false
+		}
+		if err := ctx.Err(); err != nil {
+			p.err = err
+			return false
+		}
+		p.items = p.items[:0]
+		p.pos = 0
+		p.fetch(ctx)
+	}
+	p.current = p.items[p.pos]
+	p.pos++
+
+	return true
+}
+
+// Decode 当前项按json解码到v
+func (p *Paginator) Decode(v interface{}) error {
+	return json.Unmarshal(p.current, v)
+}
+
+// Err 迭代结束后的错误
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+func (p *Paginator) fetch(ctx context.Context) {
+	if p.opts.maxPages > 0 && p.index >= p.opts.maxPages {
+		p.err = ErrMaxPagesReached
+		return
+	}
+	indexed, ok := p.strategy.(IndexedPageStrategy)
+	n := 1
+	if ok {
+		n = p.opts.concurrency
+	}
+	if p.opts.maxPages > 0 && p.index+n > p.opts.maxPages {
+		n = p.opts.maxPages - p.index
+	}
+
+	urls := []string{p.nextURL}
+	for i := 1; i < n; i++ {
+		urls = append(urls, indexed.PageURL(p.base, p.index+i))
+	}
+	pages := make([]*Page, n)
+	errs := make([]error, n)
+	if n == 1 {
+		pages[0], errs[0] = p.fetchPage(ctx, p.index, urls[0])
+	} else {
+		var wg sync.WaitGroup
+		for i := range urls {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				pages[i], errs[i] = p.fetchPage(ctx, p.index+i, urls[i])
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	// 按顺序处理, 最后一页之后的结果丢弃
+	for i, page := range pages {
+		if errs[i] != nil {
+			p.err = errs[i]
+			return
+		}
+		p.items = append(p.items, page.Items...)
+		p.index++
+		next, ok, err := p.strategy.NextURL(page)
+		if err != nil {
+			p.err = err
+			return
+		}
+		if !ok {
+			p.done = true
+			return
+		}
+		p.nextURL = next
+	}
+}
+
+func (p *Paginator) fetchPage(ctx context.Context, index int, rawURL string) (*Page, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.req.Do(ctx, http.MethodGet, rawURL, nil, p.header)
+	if err != nil {
+		return nil, err
+	}
+	body, err := resp.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	raw := resp.Raw()
+	if raw.StatusCode < http.StatusOK || raw.StatusCode >= http.StatusMultipleChoices {
+		return nil, &PageStatusError{URL: rawURL, StatusCode: raw.StatusCode, Body: string(body)}
+	}
+	page := &Page{
+		Index:    index,
+		URL:      u,
+		Response: raw,
+		Body:     body,
+	}
+	items, ok, err := lookupJSONPointer(body, p.opts.itemsPointer)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("httpclient: paginate %s: items %q not found", rawURL, p.opts.itemsPointer)
+	}
+	if !bytes.Equal(bytes.TrimSpace(items), []byte("null")) {
+		if err = json.Unmarshal(items, &page.Items); err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// lookupJSONPointer 按RFC 6901查找值, ""表示整个文档
+func lookupJSONPointer(data []byte, pointer string) (json.RawMessage, bool, error) {
+	raw := json.RawMessage(data)
+	if pointer == "" {
+		return raw, true, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, false, fmt.Errorf("httpclient: invalid json pointer %q", pointer)
+	}
+	replacer := strings.NewReplacer("~1", "/", "~0", "~")
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = replacer.Replace(token)
+		trimmed := bytes.TrimSpace(raw)
+		if len(trimmed) == 0 {
+			return nil, false, nil
+		}
+		switch trimmed[0] {
+		case '{':
+			var m map[string]json.RawMessage
+			if err := json.Unmarshal(trimmed, &m); err != nil {
+				return nil, false, err
+			}
+			v, ok := m[token]
+			if !ok {
+				return nil, false, nil
+			}
+			raw = v
+		case '[':
+			var s []json.RawMessage
+			if err := json.Unmarshal(trimmed, &s); err != nil {
+				return nil, false, err
+			}
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(s) {
+				return nil, false, nil
+			}
+			raw = s[i]
+		default:
+			return nil, false, nil
+		}
+	}
+
+	return raw, true, nil
+}
+
+type link struct {
+	url string
+	rel string
+}
+
+// parseLinkHeader 解析RFC 8288 Link头, 如<https://x/?page=2>; rel="next", <...>; rel="last"
+func parseLinkHeader(values []string) []link {
+	var links []link
+	for _, value := range values {
+		for value != "" {
+			start := strings.IndexByte(value, '<')
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(value[start:], '>')
+			if end < 0 {
+				break
+			}
+			end += start
+			l := link{url: value[start+1 : end]}
+			value = value[end+1:]
+			params := value
+			if next := strings.IndexByte(value, '<'); next >= 0 {
+				params = value[:next]
+				value = value[next:]
+			} else {
+				value = ""
+			}
+			for _, param := range strings.Split(params, ";") {
+				kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+				if len(kv) == 2 && strings.EqualFold(kv[0], "rel") {
+					l.rel = strings.Trim(strings.TrimRight(strings.TrimSpace(kv[1]), ","), `"`)
+				}
+			}
+			// rel可包含多个值, 如rel="next last"
+			for _, rel := range strings.Fields(l.rel) {
+				links = append(links, link{url: l.url, rel: strings.ToLower(rel)})
+			}
+		}
+	}
+
+	return links
+}
+
+func setQueryParam(base *url.URL, key, value string) string {
+	u := *base
+	query := u.Query()
+	query.Set(key, value)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
@@ -0,0 +1,162 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// 共10项, 每页3项
+func pageItems(offset int) []int {
+	items := []int{}
+	for i := offset; i < offset+3 && i < 10; i++ {
+		items = append(items, i)
+	}
+
+	return items
+}
+
+func collectInts(t *testing.T, p *Paginator) []int {
+	var result []int
+	for p.Next(context.Background()) {
+		var v int
+		require.NoError(t, p.Decode(&v))
+		result = append(result, v)
+	}
+
+	return result
+}
+
+func TestPaginator_LinkHeader(t *testing.T) {
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		offset, _ := strconv.Atoi(req.URL.Query().Get("offset"))
+		if offset+3 < 10 {
+			rw.Header().Set("Link", fmt.Sprintf(`</items?offset=%d>; rel="next", </items?offset=9>; rel="last"`, offset+3))
+		}
+		_ = json.NewEncoder(rw).Encode(pageItems(offset))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest()
+	p := req.Paginate(s.URL+"/items", nil, LinkHeader())
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, collectInts(t, p))
+	require.NoError(t, p.Err())
+
+	p = req.Paginate(s.URL+"/items", nil, LinkHeader(), WithMaxPages(2))
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5}, collectInts(t, p))
+	require.Equal(t, ErrMaxPagesReached, p.Err())
+}
+
+func TestPaginator_Cursor(t *testing.T) {
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		offset, _ := strconv.Atoi(req.URL.Query().Get("cursor"))
+		var next interface{}
+		if offset+3 < 10 {
+			next = strconv.Itoa(offset + 3)
+		}
+		_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+			"data": pageItems(offset),
+			"meta": map[string]interface{}{"next_cursor": next},
+		})
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest()
+	p := req.Paginate(s.URL+"?limit=3", nil, Cursor("/meta/next_cursor", "cursor"), WithItemsPointer("/data"))
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, collectInts(t, p))
+	require.NoError(t, p.Err())
+}
+
+func TestPaginator_PageNumberConcurrency(t *testing.T) {
+	var requests int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		page, _ := strconv.Atoi(req.URL.Query().Get("page"))
+		_ = json.NewEncoder(rw).Encode(map[string]interface{}{"items": pageItems((page - 1) * 3)})
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest()
+	p := req.Paginate(s.URL, nil, PageNumber("page", 1), WithItemsPointer("/items"), WithPageConcurrency(3))
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, collectInts(t, p))
+	require.NoError(t, p.Err())
+	// 第5页为空, 并发窗口为3, 共请求6页
+	require.EqualValues(t, 6, atomic.LoadInt32(&requests))
+}
+
+func TestPaginator_Offset(t *testing.T) {
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		offset, _ := strconv.Atoi(req.URL.Query().Get("offset"))
+		_ = json.NewEncoder(rw).Encode(pageItems(offset))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest()
+	p := req.Paginate(s.URL+"?limit=3", nil, Offset("offset", 3))
+	require.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, collectInts(t, p))
+	require.NoError(t, p.Err())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p = req.Paginate(s.URL, nil, Offset("offset", 3))
+	require.False(t, p.Next(ctx))
+	require.True(t, errors.Is(p.Err(), context.Canceled))
+
+	p = req.Paginate(s.URL, nil, Offset("offset", 0))
+	require.False(t, p.Next(context.Background()))
+	require.Error(t, p.Err())
+}
+
+func TestPaginator_StatusError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+
+	p := NewRequest(WithRetryTime(0)).Paginate(s.URL, nil, LinkHeader())
+	require.False(t, p.Next(context.Background()))
+	var statusErr *PageStatusError
+	require.True(t, errors.As(p.Err(), &statusErr))
+	require.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+}
+
+func TestLookupJSONPointer(t *testing.T) {
+	data := []byte(`{"a/b":{"m~n":[1,{"c":"d"}]}}`)
+	raw, ok, err := lookupJSONPointer(data, "/a~1b/m~0n/1/c")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, `"d"`, string(raw))
+
+	_, ok, err = lookupJSONPointer(data, "/a~1b/x")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, _, err = lookupJSONPointer(data, "a")
+	require.Error(t, err)
+}
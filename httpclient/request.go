@@ -17,7 +17,6 @@ package httpclient
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"io"
 	"log"
 	"mime/multipart"
@@ -54,12 +53,12 @@ const (
 
 var (
 	// 如果设置了Accept-Encoding, 不会自动解压
-	defaultHeader = map[string]string{
-		"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8",
-		"Accept-Language": "zh-CN,zh;q=0.9,en;q=0.8,ja;q=0.7",
-		"Cache-Control":   "no-cache",
-		"Pragma":          "no-cache",
-		"User-Agent":      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_13_4) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/66.0.3359.170 Safari/537.36",
+	defaultHeader = http.Header{
+		"Accept":          {"text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8"},
+		"Accept-Language": {"zh-CN,zh;q=0.9,en;q=0.8,ja;q=0.7"},
+		"Cache-Control":   {"no-cache"},
+		"Pragma":          {"no-cache"},
+		"User-Agent":      {"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"},
 	}
 )
 
@@ -67,22 +66,49 @@ type RequestInterceptor func(req *http.Request)
 type ResponseInterceptor func(req *http.Request, resp *http.Response, err error)
 
 type options struct {
-	client              *http.Client
-	debug               bool
-	cookieJar           http.CookieJar
-	timeout             time.Duration
-	connectTimeout      time.Duration
-	maxIdleConnsPerHost int
-	proxyURL            string
-	retryTimes          int
-	enableDefaultHeader bool
-	disableKeepAlive    bool
-	dnsResolver         DNSResolverFunc
-	unixSocketPath      string
-	shouldRetryFunc     func(*http.Request, *http.Response, error) bool
-	requestInterceptor  RequestInterceptor
-	responseInterceptor ResponseInterceptor
-	clientTrace         *httptrace.ClientTrace
+	client                 *http.Client
+	transport              http.RoundTripper
+	debug                  bool
+	cookieJar              http.CookieJar
+	timeout                time.Duration
+	connectTimeout         time.Duration
+	maxIdleConnsPerHost    int
+	proxyURL               *url.URL
+	proxyFromEnv           bool
+	proxyRules             []ProxyRule
+	proxyPool              *ProxyPool
+	proxyConnectHeader     http.Header
+	retryTimes             int
+	defaultHeader          http.Header
+	disableKeepAlive       bool
+	dnsResolver            DNSResolverFunc
+	unixSocketPath         string
+	shouldRetryFunc        func(*http.Request, *http.Response, error) bool
+	requestInterceptor     RequestInterceptor
+	responseInterceptor    ResponseInterceptor
+	clientTrace            *httptrace.ClientTrace
+	hedgingDelay           time.Duration
+	maxHedges              int
+	coalescing             bool
+	coalesceHeaders        []string
+	decompress             bool
+	maxDecompressedBytes   int64
+	requestEncoding        string
+	requestCompressMinSize int
+	maxResponseBytes       int64
+	maxDownloadBytes       int64
+	bodyReadTimeout        time.Duration
+	websocketPingInterval  time.Duration
+	websocketCompression   bool
+	socketDialers          map[string]SocketDialFunc
+	harRecorder            *HARRecorder
+	limiter                *AdaptiveLimiter
+	idempotencyKey         bool
+	retryNonIdempotent     bool
+	attemptTimeout         time.Duration
+	totalTimeout           time.Duration
+	auth                   []func(*http.Request)
+	err                    error
 }
 
 // DNSResolverFunc DNS解析
@@ -99,6 +125,13 @@ func WithClient(client *http.Client) Option {
 	}
 }
 
+// WithTransport 自定义transport, 如录制回放、mock
+func WithTransport(transport http.RoundTripper) Option {
+	return func(opt *options) {
+		opt.transport = transport
+	}
+}
+
 func WithClientTrace(t *httptrace.ClientTrace) Option {
 	return func(opt *options) {
 		opt.clientTrace = t
@@ -136,11 +169,9 @@ func WithShouldRetryFunc(f func(*http.Request, *http.Response, error) bool) Opti
 	}
 }
 
-// WithEnableDefaultHeader 设置默认header
+// WithEnableDefaultHeader 设置默认header, 需要完整的浏览器header时使用WithBrowserProfile
 func WithEnableDefaultHeader() Option {
-	return func(opt *options) {
-		opt.enableDefaultHeader = true
-	}
+	return WithDefaultHeaders(defaultHeader)
 }
 
 // WithRetryTime 设置重试次数
@@ -150,20 +181,13 @@ func WithRetryTime(retryTimes int) Option {
 	}
 }
 
-// WithProxyURL 设置代理
-func WithProxyURL(proxyURL string) Option {
-	return func(opt *options) {
-		opt.proxyURL = proxyURL
-	}
-}
-
 func WithUnixSocketPath(path string) Option {
 	return func(opt *options) {
 		opt.unixSocketPath = path
 	}
 }
 
-// WithTimeout 设置超时
+// WithTimeout 设置http.Client超时, 作用于每次尝试, 整个调用的超时使用WithTotalTimeout
 func WithTimeout(timeout time.Duration) Option {
 	return func(opt *options) {
 		opt.timeout = timeout
@@ -200,19 +224,37 @@ func WithDebug() Option {
 
 // Request http请求
 type Request struct {
-	opts options
+	// opt 创建时的参数, Clone时重新应用
+	opt      []Option
+	opts     options
+	coalesce *coalesceGroup
+	dial     DialContext
+	stats    *connStats
 }
 
-// NewRequest 创建request
+// NewRequest 创建request, 参数错误时panic
 func NewRequest(opt ...Option) *Request {
-	req := &Request{}
+	req, err := NewRequestWithError(opt...)
+	if err != nil {
+		panic(err)
+	}
+
+	return req
+}
+
+// NewRequestWithError 创建request, 参数错误时返回error
+func NewRequestWithError(opt ...Option) (*Request, error) {
+	req := &Request{opt: append([]Option(nil), opt...)}
 	req.opts = options{}
 	for _, o := range opt {
 		o(&req.opts)
 	}
+	if req.opts.err != nil {
+		return nil, req.opts.err
+	}
 	req.init()
 
-	return req
+	return req, nil
 }
 
 func (req *Request) init() {
@@ -226,18 +268,21 @@ func (req *Request) init() {
 	if req.opts.maxIdleConnsPerHost <= 0 {
 		req.opts.maxIdleConnsPerHost = defaultMaxIdleConnsPerHost
 	}
+	req.dial = (&net.Dialer{
+		Timeout:   req.opts.connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	if req.opts.dnsResolver != nil {
+		req.dial = req.dialContext()
+	}
+	if req.opts.unixSocketPath != "" {
+		req.dial = req.dialContextForUnixDomainSocket
+	}
+	req.stats = newConnStats()
 	trans := &http.Transport{
-		Proxy: func(request *http.Request) (*url.URL, error) {
-			if req.opts.proxyURL != "" {
-				return url.Parse(req.opts.proxyURL)
-			}
-
-			return nil, nil
-		},
-		DialContext: (&net.Dialer{
-			Timeout:   req.opts.connectTimeout,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
+		Proxy:                 req.proxy,
+		ProxyConnectHeader:    req.opts.proxyConnectHeader,
+		DialContext:           req.stats.wrapDial(req.dial),
 		MaxIdleConns:          100,
 		MaxIdleConnsPerHost:   req.opts.maxIdleConnsPerHost,
 		IdleConnTimeout:       10 * time.Second,
@@ -247,20 +292,32 @@ func (req *Request) init() {
 	if req.opts.disableKeepAlive {
 		trans.DisableKeepAlives = true
 	}
+	if req.opts.decompress {
+		trans.DisableCompression = true
+	}
 
 	if req.opts.client == nil {
 		req.opts.client = &http.Client{
 			Timeout: req.opts.timeout,
 		}
+	} else {
+		// 复制一份, 包装Transport、设置Jar不影响调用方的client
+		client := *req.opts.client
+		req.opts.client = &client
 	}
-	if req.opts.dnsResolver != nil {
-		trans.DialContext = req.dialContext()
-	}
-	if req.opts.unixSocketPath != "" {
-		trans.DialContext = req.dialContextForUnixDomainSocket
+	req.registerSocketProtocols(trans)
+	if req.opts.transport != nil {
+		req.opts.client.Transport = req.opts.transport
 	}
 	if req.opts.client.Transport == nil {
 		req.opts.client.Transport = trans
+		if req.opts.proxyPool != nil {
+			req.opts.client.Transport = req.opts.proxyPool.wrap(trans)
+		}
+	}
+	req.opts.client.Transport = req.stats.wrap(req.opts.client.Transport)
+	if req.opts.harRecorder != nil {
+		req.opts.client.Transport = req.opts.harRecorder.wrap(req.opts.client.Transport)
 	}
 	if req.opts.shouldRetryFunc == nil {
 		req.opts.shouldRetryFunc = req.shouldRetry
@@ -268,6 +325,43 @@ func (req *Request) init() {
 	if req.opts.cookieJar != nil {
 		req.opts.client.Jar = req.opts.cookieJar
 	}
+	if req.opts.coalescing {
+		req.coalesce = newCoalesceGroup()
+	}
+}
+
+// Clone 使用创建时的参数和opt创建新的Request, opt在原参数之后应用, 参数错误时panic
+// opt中有WithClient、WithTransport或代理、拨号、连接池等Transport参数时使用新的Transport,
+// 否则与原Request共享Transport(连接池), 连接统计计入原Request
+func (req *Request) Clone(opt ...Option) *Request {
+	opts := req.opt[:len(req.opt):len(req.opt)]
+	if !hasTransportOption(opt) {
+		opts = append(opts, WithTransport(baseTransport(req.opts.client.Transport)))
+	}
+
+	return NewRequest(append(opts, opt...)...)
+}
+
+// hasTransportOption opt中是否有需要新建Transport的参数
+func hasTransportOption(opt []Option) bool {
+	var o options
+	for _, fn := range opt {
+		fn(&o)
+	}
+
+	return o.client != nil || o.transport != nil || o.connectTimeout != 0 || o.maxIdleConnsPerHost != 0 ||
+		o.proxyURL != nil || o.proxyFromEnv || len(o.proxyRules) > 0 || o.proxyPool != nil || o.proxyConnectHeader != nil ||
+		o.disableKeepAlive || o.dnsResolver != nil || o.unixSocketPath != "" || o.decompress || len(o.socketDialers) > 0
+}
+
+// cloneHeader 复制header, header为nil时返回空header
+func cloneHeader(header http.Header) http.Header {
+	h := make(http.Header, len(header))
+	for k, v := range header {
+		h[k] = append([]string(nil), v...)
+	}
+
+	return h
 }
 
 // Get get请求
@@ -295,17 +389,34 @@ func (req *Request) Delete(url string, data interface{}, header http.Header) (*R
 
 // PostJSON 发送json body
 func (req *Request) PostJSON(url string, data interface{}, header http.Header) (*Response, error) {
-	if header == nil {
-		header = make(http.Header)
-	}
-	header.Set("Content-Type", "application/json")
+	return req.PostAs(url, MediaTypeJSON, data, header)
+}
+
+// PostProtoBuf 发送protoBuf body
+func (req *Request) PostProtoBuf(url string, v proto.Message, header http.Header) (*Response, error) {
+	return req.PostAs(url, MediaTypeProtoBuf, v, header)
+}
+
+// PostXML 发送xml body
+func (req *Request) PostXML(url string, data interface{}, header http.Header) (*Response, error) {
+	return req.PostAs(url, MediaTypeXML, data, header)
+}
+
+// PostAs 使用mediaType对应的codec编码data并发送, data为string、[]byte、io.Reader时不编码
+func (req *Request) PostAs(url string, mediaType string, data interface{}, header http.Header) (*Response, error) {
+	header = cloneHeader(header)
+	header.Set("Content-Type", mediaType)
 	var body interface{}
 	switch data.(type) {
 	case string, []byte, io.Reader:
 		body = data
 	default:
+		codec, ok := LookupCodec(mediaType)
+		if !ok {
+			return nil, &UnsupportedMediaTypeError{MediaType: mediaType}
+		}
 		var err error
-		body, err = json.Marshal(data)
+		body, err = codec.Marshal(data)
 		if err != nil {
 			return nil, err
 		}
@@ -314,20 +425,6 @@ func (req *Request) PostJSON(url string, data interface{}, header http.Header) (
 	return req.Do(context.Background(), http.MethodPost, url, body, header)
 }
 
-// PostProtoBuf 发送protoBuf body
-func (req *Request) PostProtoBuf(url string, v proto.Message, header http.Header) (*Response, error) {
-	if header == nil {
-		header = make(http.Header)
-	}
-	header.Set("Content-Type", "application/x-protobuf")
-	body, err := proto.Marshal(v)
-	if err != nil {
-		return nil, err
-	}
-
-	return req.Do(context.Background(), http.MethodPost, url, body, header)
-}
-
 // UploadFile 上传文件
 func (req *Request) UploadFile(url string, reader io.Reader, filename string, header http.Header, params map[string]string) (*Response, error) {
 	pipeReader, pipeWriter := io.Pipe()
@@ -353,9 +450,7 @@ func (req *Request) UploadFile(url string, reader io.Reader, filename string, he
 			_ = mr.WriteField(k, v)
 		}
 	}()
-	if header == nil {
-		header = make(http.Header)
-	}
+	header = cloneHeader(header)
 	header.Set("Content-Type", mr.FormDataContentType())
 
 	resp, respErr := req.Post(url, pipeReader, header)
@@ -364,6 +459,14 @@ func (req *Request) UploadFile(url string, reader io.Reader, filename string, he
 }
 
 func (req *Request) Do(ctx context.Context, method string, url string, data interface{}, header http.Header) (*Response, error) {
+	if req.shouldCoalesce(method, data) {
+		return req.doCoalesced(ctx, method, url, header)
+	}
+
+	return req.do(ctx, method, url, data, header)
+}
+
+func (req *Request) do(ctx context.Context, method string, url string, data interface{}, header http.Header) (*Response, error) {
 	execTimes := 1
 	retryInterval := 300 * time.Millisecond
 	if req.opts.retryTimes > 0 {
@@ -377,20 +480,70 @@ func (req *Request) Do(ctx context.Context, method string, url string, data inte
 	if metricValue != nil {
 		metric, _ = metricValue.Load().(*Metric)
 	}
+	header, err = req.withIdempotencyKey(method, header)
+	if err != nil {
+		return nil, err
+	}
+	// 成功时由body关闭释放
+	cancelTotal, keepTotal := context.CancelFunc(func() {}), false
+	if req.opts.totalTimeout > 0 {
+		ctx, cancelTotal = context.WithTimeout(ctx, req.opts.totalTimeout)
+	}
+	defer func() {
+		if !keepTotal {
+			cancelTotal()
+		}
+	}()
+	var fastest time.Duration
 
 	for i := 0; i < execTimes; {
 		if resp != nil && resp.Body != nil {
 			_ = resp.Body.Close()
 		}
-		targetReq, err = req.build(ctx, method, url, data, header)
+		attemptCtx, cancel := ctx, context.CancelFunc(nil)
+		if req.opts.attemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, req.opts.attemptTimeout)
+		} else if req.opts.bodyReadTimeout > 0 {
+			attemptCtx, cancel = context.WithCancel(ctx)
+		}
+		targetReq, err = req.build(attemptCtx, method, url, data, header)
 		if err != nil {
+			if cancel != nil {
+				cancel()
+			}
 			return nil, err
 		}
 		req.beforeRequest(targetReq)
-		if metric != nil {
-			startTime = time.Now()
+		var release func(LimitOutcome)
+		if req.opts.limiter != nil {
+			release, err = req.opts.limiter.Acquire(attemptCtx)
+			if err != nil {
+				if cancel != nil {
+					cancel()
+				}
+				return nil, err
+			}
+		}
+		startTime = time.Now()
+		resp, err = req.send(targetReq, metric)
+		if elapsed := time.Since(startTime); fastest == 0 || elapsed < fastest {
+			fastest = elapsed
+		}
+		if release != nil {
+			release(limitOutcome(resp, err))
+		}
+		if err == nil {
+			err = req.decompressResponse(resp)
+		}
+		if cancel != nil {
+			if err == nil && req.opts.bodyReadTimeout > 0 {
+				resp.Body = newIdleTimeoutBody(resp.Body, req.opts.bodyReadTimeout, cancel)
+			} else if err == nil {
+				resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+			} else {
+				cancel()
+			}
 		}
-		resp, err = req.opts.client.Do(targetReq)
 		if metric != nil {
 			metric.Count(targetReq.URL, err)
 			metric.Latency(targetReq.URL, time.Since(startTime))
@@ -402,22 +555,61 @@ func (req *Request) Do(ctx context.Context, method string, url string, data inte
 		i++
 		retryInterval *= 2
 		if i < execTimes {
-			time.Sleep(retryInterval)
+			// 剩余时间内不可能完成下一次尝试
+			if !canRetryWithin(ctx, retryInterval, fastest) {
+				break
+			}
+			timer := time.NewTimer(retryInterval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				if resp != nil && resp.Body != nil {
+					_ = resp.Body.Close()
+				}
+				return nil, ctx.Err()
+			}
 		}
 	}
+	if err == nil && req.opts.totalTimeout > 0 {
+		resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancelTotal}
+		keepTotal = true
+	}
 
-	return newResponse(resp), err
+	return req.newResponse(resp), err
+}
+
+// 发送请求, 满足条件时使用对冲请求
+func (req *Request) send(r *http.Request, metric *Metric) (*http.Response, error) {
+	if req.shouldHedge(r) {
+		return req.doHedged(r, metric)
+	}
+
+	return req.opts.client.Do(r)
 }
 
 // 构造http.Request
 func (req *Request) build(ctx context.Context, method string, url string, data interface{}, header http.Header) (*http.Request, error) {
-	body := req.makeBody(data)
+	// 每次尝试使用单独的header, 不修改调用方的header
+	header = cloneHeader(header)
+	body, err := req.makeBody(data)
+	if err != nil {
+		return nil, err
+	}
+	body, err = req.compressBody(body, header)
+	if err != nil {
+		return nil, err
+	}
+	url, err = req.rewriteSocketURL(url)
+	if err != nil {
+		return nil, err
+	}
 	targetReq, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
-	if header == nil {
-		header = make(http.Header)
+	if req.opts.decompress && header.Get("Accept-Encoding") == "" {
+		header.Set("Accept-Encoding", acceptEncoding())
 	}
 	if method != http.MethodGet && header.Get("Content-Type") == "" {
 		header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -427,10 +619,9 @@ func (req *Request) build(ctx context.Context, method string, url string, data i
 	if host != "" {
 		targetReq.Host = host
 	}
-	if req.opts.enableDefaultHeader {
-		for k, v := range defaultHeader {
-			targetReq.Header.Add(k, v)
-		}
+	req.applyDefaultHeader(targetReq)
+	for _, auth := range req.opts.auth {
+		auth(targetReq)
 	}
 	if req.opts.clientTrace != nil {
 		targetReq = targetReq.WithContext(httptrace.WithClientTrace(targetReq.Context(), req.opts.clientTrace))
@@ -482,8 +673,11 @@ func (req *Request) dumpResponseIfNeed(resp *http.Response, err error) {
 	log.Printf("[Response]\n\n %s\n", respDump)
 }
 
-// 是否要重试
+// 是否要重试, 非幂等请求只在连接建立失败时重试, request为nil时不区分方法
 func (req *Request) shouldRetry(request *http.Request, resp *http.Response, err error) bool {
+	if request != nil && !req.opts.retryNonIdempotent && !isIdempotent(request) {
+		return IsConnectError(err)
+	}
 	if err != nil {
 		return true
 	}
@@ -518,10 +712,10 @@ func (req *Request) makeURLWithParams(url string, data url.Values) string {
 	return url
 }
 
-// 生成请求Body
-func (req *Request) makeBody(data interface{}) io.Reader {
+// 生成请求Body, 结构体和map按表单编码
+func (req *Request) makeBody(data interface{}) (io.Reader, error) {
 	if data == nil {
-		return nil
+		return nil, nil
 	}
 	var body io.Reader
 	switch v := data.(type) {
@@ -534,10 +728,14 @@ func (req *Request) makeBody(data interface{}) io.Reader {
 	case io.Reader:
 		body = v
 	default:
-		panic("data is not support type")
+		values, err := EncodeForm(data)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(values.Encode())
 	}
 
-	return body
+	return body, nil
 }
 
 func (req *Request) dialContext() DialContext {
@@ -547,11 +745,6 @@ func (req *Request) dialContext() DialContext {
 			KeepAlive: 30 * time.Second,
 		}
 
-		u, err := url.Parse(addr)
-		if err == nil && u.Scheme == "unix" {
-			return dialer.DialContext(ctx, u.Scheme, u.Path)
-		}
-
 		separator := strings.LastIndex(addr, ":")
 		ip, err := req.opts.dnsResolver(addr[:separator])
 		if err != nil {
@@ -565,10 +758,5 @@ func (req *Request) dialContext() DialContext {
 }
 
 func (req *Request) dialContextForUnixDomainSocket(ctx context.Context, network, address string) (net.Conn, error) {
-	dialer := &net.Dialer{
-		Timeout:   req.opts.connectTimeout,
-		KeepAlive: 30 * time.Second,
-	}
-
-	return dialer.DialContext(ctx, "unix", req.opts.unixSocketPath)
+	return req.dialUnixSocket(ctx, req.opts.unixSocketPath)
 }
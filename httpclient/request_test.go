@@ -18,17 +18,18 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	"errors"
-
-	"github.com/ouqiang/goutil"
 )
 
 func TestRequest_Get(t *testing.T) {
@@ -58,35 +59,47 @@ func TestRequest_Get(t *testing.T) {
 
 func TestRequest_makeBody(t *testing.T) {
 	req := NewRequest()
-	r := req.makeBody(nil)
+	r, err := req.makeBody(nil)
+	require.NoError(t, err)
 	require.Nil(t, r)
 	s := "name=golang"
-	r = req.makeBody(s)
+	r, err = req.makeBody(s)
+	require.NoError(t, err)
 	out, err := ioutil.ReadAll(r)
 	require.NoError(t, err)
 	require.Equal(t, s, string(out))
 
 	b := []byte(s)
-	r = req.makeBody(b)
+	r, err = req.makeBody(b)
+	require.NoError(t, err)
 	out, err = ioutil.ReadAll(r)
 	require.NoError(t, err)
 	require.Equal(t, s, string(out))
 
 	v := url.Values{}
 	v.Add("name", "golang")
-	r = req.makeBody(v)
+	r, err = req.makeBody(v)
+	require.NoError(t, err)
 	out, err = ioutil.ReadAll(r)
 	require.NoError(t, err)
 	require.Equal(t, s, string(out))
 
-	r = req.makeBody(strings.NewReader(s))
+	r, err = req.makeBody(strings.NewReader(s))
+	require.NoError(t, err)
 	out, err = ioutil.ReadAll(r)
 	require.NoError(t, err)
 	require.Equal(t, s, string(out))
-	err = goutil.PanicToError(func() {
-		r = req.makeBody(1)
-	})
-	require.NotNil(t, err)
+
+	r, err = req.makeBody(struct {
+		Name string `form:"name"`
+	}{"golang"})
+	require.NoError(t, err)
+	out, err = ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, s, string(out))
+
+	_, err = req.makeBody(1)
+	require.Error(t, err)
 }
 
 func TestRequest_makeURLWithParams(t *testing.T) {
@@ -167,6 +180,27 @@ func TestRequest_PostJSON(t *testing.T) {
 	require.Equal(t, jsonString, body)
 }
 
+func TestRequest_PostXML(t *testing.T) {
+	type user struct {
+		Name string `xml:"name"`
+	}
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Content-Type") != "application/xml" {
+			panic("invalid content-type")
+		}
+		_, _ = io.Copy(rw, req.Body)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest()
+	resp, err := req.PostXML(s.URL, &user{Name: "golang"}, nil)
+	require.NoError(t, err)
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "<user><name>golang</name></user>", body)
+}
+
 func TestRequest_PostProtoBuf(t *testing.T) {
 	message := &Message{
 		Name: "protobuf",
@@ -251,3 +285,153 @@ func TestRequest_SetRetryTimes(t *testing.T) {
 	require.Equal(t, http.StatusNotFound, resp.Raw().StatusCode)
 	require.Equal(t, -1, retryTimes)
 }
+
+func TestRequest_ConcurrentSharedHeader(t *testing.T) {
+	var seen sync.Map
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		// 每个请求的第一次尝试失败, 触发重试
+		if _, ok := seen.LoadOrStore(req.URL.RawQuery, true); !ok {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if len(req.Header["Content-Type"]) > 1 || len(req.Header["X-Default"]) != 1 || len(req.Header["X-Shared"]) != 1 {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = io.WriteString(rw, req.Header.Get("Content-Type"))
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest(
+		WithRetryTime(3),
+		WithRetryNonIdempotent(),
+		WithDefaultHeaders(http.Header{"X-Default": {"1"}}),
+		WithIdempotencyKey(),
+	)
+	header := http.Header{"X-Shared": {"1"}}
+	check := func(resp *Response, err error, contentType string) error {
+		if err != nil {
+			return err
+		}
+		body, err := resp.String()
+		if err != nil {
+			return err
+		}
+		if !resp.IsStatusSuccess() || body != contentType {
+			return fmt.Errorf("unexpected response %d %q", resp.Raw().StatusCode, body)
+		}
+		return nil
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 60)
+	for i := 0; i < 20; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := req.Get(fmt.Sprintf("%s?get=%d", s.URL, i), nil, header)
+			errs <- check(resp, err, "")
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := req.PostJSON(fmt.Sprintf("%s?json=%d", s.URL, i), map[string]int{"id": 1}, header)
+			errs <- check(resp, err, MediaTypeJSON)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := req.Post(fmt.Sprintf("%s?form=%d", s.URL, i), "a=1", header)
+			errs <- check(resp, err, "application/x-www-form-urlencoded")
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, http.Header{"X-Shared": {"1"}}, header)
+}
+
+func TestRequest_Clone(t *testing.T) {
+	var got *http.Request
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		got = req
+	}))
+	defer s.Close()
+
+	client := s.Client()
+	transport := client.Transport
+	req := NewRequest(WithClient(client), WithDefaultHeaders(http.Header{"X-Base": {"1"}}))
+	// 不修改调用方的client
+	require.Equal(t, transport, client.Transport)
+
+	derived := req.Clone(WithBearerToken("token"))
+	_, err := derived.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, "1", got.Header.Get("X-Base"))
+	require.Equal(t, "Bearer token", got.Header.Get("Authorization"))
+
+	_, err = req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, got.Header.Get("Authorization"))
+
+	require.Panics(t, func() {
+		req.Clone(WithBrowserProfile("netscape"))
+	})
+}
+
+func TestRequest_CloneSharedTransport(t *testing.T) {
+	var conns int32
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(req.Header.Get(IdempotencyKeyHeader)))
+	}))
+	s.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	s.Start()
+	defer s.Close()
+
+	req := NewRequest()
+	derived := req.Clone(WithIdempotencyKey())
+	for _, r := range []*Request{req, derived, req, derived} {
+		resp, err := r.Get(s.URL, nil, nil)
+		require.NoError(t, err)
+		_, err = resp.String()
+		require.NoError(t, err)
+	}
+	// 共享连接池, 只建立一个连接
+	require.EqualValues(t, 1, atomic.LoadInt32(&conns))
+
+	resp, err := derived.Post(s.URL, nil, nil)
+	require.NoError(t, err)
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.NotEmpty(t, body)
+}
+
+func TestRequest_CloneTransportOptions(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(rw, "direct")
+	}))
+	defer target.Close()
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(rw, "proxy "+req.URL.String())
+	}))
+	defer proxy.Close()
+
+	get := func(r *Request) string {
+		resp, err := r.Get(target.URL, nil, nil)
+		require.NoError(t, err)
+		body, err := resp.String()
+		require.NoError(t, err)
+		return body
+	}
+	req := NewRequest()
+	require.Equal(t, "direct", get(req))
+	// Transport参数在Clone中生效, 不影响原Request
+	require.Equal(t, "proxy "+target.URL+"/", get(req.Clone(WithProxyURL(proxy.URL))))
+	require.Equal(t, "direct", get(req))
+	require.False(t, hasTransportOption([]Option{WithRetryTime(1), WithBearerToken("token")}))
+	require.True(t, hasTransportOption([]Option{WithDisableKeepAlive()}))
+}
@@ -16,6 +16,7 @@ package httpclient
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -26,12 +27,18 @@ import (
 
 // Response http响应
 type Response struct {
-	rawResp *http.Response
+	rawResp          *http.Response
+	maxBytes         int64
+	maxDownloadBytes int64
 }
 
-// newResponse 创建response
-func newResponse(resp *http.Response) *Response {
-	return &Response{resp}
+// newResponse 创建response, 使用request的body大小限制
+func (req *Request) newResponse(resp *http.Response) *Response {
+	return &Response{
+		rawResp:          resp,
+		maxBytes:         req.opts.maxResponseBytes,
+		maxDownloadBytes: req.opts.maxDownloadBytes,
+	}
 }
 
 // IsStatusOK 响应码是否为200
@@ -41,7 +48,15 @@ func (resp *Response) IsStatusOK() bool {
 
 // DecodeJSON  json decode
 func (resp *Response) DecodeJSON(v interface{}) error {
-	err := json.NewDecoder(resp.rawResp.Body).Decode(v)
+	err := json.NewDecoder(limitReader(resp.rawResp.Body, resp.maxBytes)).Decode(v)
+	_ = resp.rawResp.Body.Close()
+
+	return err
+}
+
+// DecodeXML xml decode
+func (resp *Response) DecodeXML(v interface{}) error {
+	err := xml.NewDecoder(limitReader(resp.rawResp.Body, resp.maxBytes)).Decode(v)
 	_ = resp.rawResp.Body.Close()
 
 	return err
@@ -59,6 +74,25 @@ func (resp *Response) DecodeProtoBuf(v interface{}) error {
 	return err
 }
 
+// Decode 根据Content-Type选择codec解码, 未设置Content-Type时使用json
+func (resp *Response) Decode(v interface{}) error {
+	contentType := resp.rawResp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = MediaTypeJSON
+	}
+	codec, ok := LookupCodec(contentType)
+	if !ok {
+		_, _ = resp.Discard()
+		return &UnsupportedMediaTypeError{MediaType: normalizeMediaType(contentType)}
+	}
+	data, err := resp.Bytes()
+	if err != nil {
+		return err
+	}
+
+	return codec.Unmarshal(data, v)
+}
+
 // String 读取http.Body, 返回string
 func (resp *Response) String() (string, error) {
 	b, err := resp.Bytes()
@@ -71,7 +105,7 @@ func (resp *Response) String() (string, error) {
 
 // Bytes 读取http.Body, 返回bytes
 func (resp *Response) Bytes() ([]byte, error) {
-	b, err := ioutil.ReadAll(resp.rawResp.Body)
+	b, err := ioutil.ReadAll(limitReader(resp.rawResp.Body, resp.maxBytes))
 	_ = resp.rawResp.Body.Close()
 
 	return b, err
@@ -92,17 +126,19 @@ func (resp *Response) WriteFile(filename string) (int64, error) {
 		_, _ = resp.Discard()
 		return 0, err
 	}
-	defer func() {
-		_ = f.Close()
-		_ = resp.rawResp.Body.Close()
-	}()
+	n, err := io.Copy(f, limitReader(resp.rawResp.Body, resp.maxDownloadBytes))
+	_ = f.Close()
+	_ = resp.rawResp.Body.Close()
+	if _, ok := err.(*ResponseTooLargeError); ok {
+		_ = os.Remove(filename)
+	}
 
-	return io.Copy(f, resp.rawResp.Body)
+	return n, err
 }
 
 // WriteTo 读取http.Body并写入w中
 func (resp *Response) WriteTo(w io.Writer) (int64, error) {
-	n, err := io.Copy(w, resp.rawResp.Body)
+	n, err := io.Copy(w, limitReader(resp.rawResp.Body, resp.maxDownloadBytes))
 	_ = resp.rawResp.Body.Close()
 
 	return n, err
@@ -68,6 +68,25 @@ func TestResponse_DecodeJSON(t *testing.T) {
 	require.Equal(t, http.StatusOK, apiResponse.Code)
 }
 
+func TestResponse_DecodeXML(t *testing.T) {
+	xmlString := `<user><name>golang</name></user>`
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(rw, xmlString)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest()
+	resp, err := req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	var user struct {
+		Name string `xml:"name"`
+	}
+	err = resp.DecodeXML(&user)
+	require.NoError(t, err)
+	require.Equal(t, "golang", user.Name)
+}
+
 func TestResponse_DecodeProtoBuf(t *testing.T) {
 	handler := func(rw http.ResponseWriter, req *http.Request) {
 		message := &Message{
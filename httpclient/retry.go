@@ -0,0 +1,105 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// IdempotencyKeyHeader 幂等键header
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// WithIdempotencyKey 非幂等方法(POST、PATCH)自动添加Idempotency-Key, 同一次Do的所有重试使用相同的值
+// 带有Idempotency-Key的请求视为幂等, 可以重试
+func WithIdempotencyKey() Option {
+	return func(opt *options) {
+		opt.idempotencyKey = true
+	}
+}
+
+// WithRetryNonIdempotent 非幂等方法也按幂等方法的条件重试, 可能导致重复提交
+func WithRetryNonIdempotent() Option {
+	return func(opt *options) {
+		opt.retryNonIdempotent = true
+	}
+}
+
+// IsConnectError 建立连接失败, 请求未发出, 如连接被拒绝、DNS解析失败, 任何方法重试都是安全的
+func IsConnectError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	return false
+}
+
+// isIdempotent 幂等方法或带有Idempotency-Key的请求
+func isIdempotent(r *http.Request) bool {
+	return isIdempotentMethod(r.Method) ||
+		r.Header.Get(IdempotencyKeyHeader) != "" || r.Header.Get("X-Idempotency-Key") != ""
+}
+
+// isIdempotentMethod RFC 7231定义的幂等方法, 空字符串即GET
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// withIdempotencyKey 需要时复制header并添加幂等键, 不修改调用方的header
+func (req *Request) withIdempotencyKey(method string, header http.Header) (http.Header, error) {
+	if !req.opts.idempotencyKey || isIdempotentMethod(method) || header.Get(IdempotencyKeyHeader) != "" {
+		return header, nil
+	}
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
+	h := cloneHeader(header)
+	h.Set(IdempotencyKeyHeader, key)
+
+	return h, nil
+}
+
+// newIdempotencyKey 生成UUID v4
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_RetryPolicy(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		keys = append(keys, req.Header.Get(IdempotencyKeyHeader))
+		mu.Unlock()
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+	reset := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		k := keys
+		keys = nil
+		return k
+	}
+
+	// 默认不重试POST
+	_, err := NewRequest(WithRetryTime(2)).Post(s.URL, "a=1", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{""}, reset())
+
+	// 幂等方法照常重试
+	_, err = NewRequest(WithRetryTime(2)).Put(s.URL, "a=1", nil)
+	require.NoError(t, err)
+	require.Len(t, reset(), 3)
+
+	// 开启幂等键后重试, 所有尝试使用相同的键, 调用方的header不被修改
+	header := make(http.Header)
+	req := NewRequest(WithRetryTime(2), WithIdempotencyKey())
+	_, err = req.Post(s.URL, "a=1", header)
+	require.NoError(t, err)
+	got := reset()
+	require.Len(t, got, 3)
+	require.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), got[0])
+	require.Equal(t, got[0], got[1])
+	require.Equal(t, got[0], got[2])
+	require.Empty(t, header.Get(IdempotencyKeyHeader))
+
+	// 每次调用生成新的键
+	_, err = req.Post(s.URL, "a=1", nil)
+	require.NoError(t, err)
+	require.NotEqual(t, got[0], reset()[0])
+
+	// 调用方指定的键保持不变
+	header.Set(IdempotencyKeyHeader, "order-1")
+	_, err = NewRequest(WithRetryTime(1)).Post(s.URL, "a=1", header)
+	require.NoError(t, err)
+	require.Equal(t, []string{"order-1", "order-1"}, reset())
+
+	_, err = NewRequest(WithRetryTime(1), WithRetryNonIdempotent()).Post(s.URL, "a=1", nil)
+	require.NoError(t, err)
+	require.Len(t, reset(), 2)
+}
+
+func TestRequest_RetryConnectError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	var attempts int32
+	interceptor := func(r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+	}
+	req := NewRequest(WithRetryTime(1), WithRequestInterceptor(interceptor))
+	_, err = req.Post("http://"+addr, "a=1", nil)
+	require.Error(t, err)
+	require.True(t, IsConnectError(err))
+	require.EqualValues(t, 2, atomic.LoadInt32(&attempts))
+}
+
+func TestRequest_RetryReadTimeout(t *testing.T) {
+	var attempts int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		time.Sleep(100 * time.Millisecond)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	// 请求可能已被处理, 非幂等方法不重试
+	_, err := NewRequest(WithRetryTime(1), WithTimeout(20*time.Millisecond)).Post(s.URL, "a=1", nil)
+	require.Error(t, err)
+	require.False(t, IsConnectError(err))
+	require.EqualValues(t, 1, atomic.LoadInt32(&attempts))
+}
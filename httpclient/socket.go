@@ -0,0 +1,140 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SocketDialFunc 按地址建立连接, 如unix socket路径、vsock的cid:port
+type SocketDialFunc func(ctx context.Context, address string) (net.Conn, error)
+
+// WithSocketDialer 注册http+<scheme>://地址的拨号方式, 如vsock
+// 内置unix, 支持http+unix://%2Fvar%2Frun%2Fdocker.sock/path和抽象命名空间http+unix://%40name/path
+// 仅在未通过WithTransport、WithClient自定义transport时生效
+func WithSocketDialer(scheme string, dial SocketDialFunc) Option {
+	return func(opt *options) {
+		if opt.socketDialers == nil {
+			opt.socketDialers = make(map[string]SocketDialFunc)
+		}
+		opt.socketDialers[strings.ToLower(scheme)] = dial
+	}
+}
+
+func (req *Request) dialUnixSocket(ctx context.Context, address string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   req.opts.connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	return dialer.DialContext(ctx, "unix", address)
+}
+
+// 为http+<scheme>注册RoundTripper, 需在trans配置完成后调用
+func (req *Request) registerSocketProtocols(trans *http.Transport) {
+	dialers := map[string]SocketDialFunc{
+		"unix": req.dialUnixSocket,
+	}
+	for scheme, dial := range req.opts.socketDialers {
+		dialers[scheme] = dial
+	}
+	for scheme, dial := range dialers {
+		trans.RegisterProtocol("http+"+scheme, newSocketTransport(trans, dial))
+	}
+}
+
+// socketScheme 返回http+<scheme>中的scheme, 非socket地址返回空
+func (req *Request) socketScheme(scheme string) string {
+	scheme = strings.ToLower(scheme)
+	if !strings.HasPrefix(scheme, "http+") {
+		return ""
+	}
+	scheme = strings.TrimPrefix(scheme, "http+")
+	if _, ok := req.opts.socketDialers[scheme]; ok || scheme == "unix" {
+		return scheme
+	}
+
+	return ""
+}
+
+// rewriteSocketURL 把http+unix://%2Fvar%2Frun%2Fdocker.sock/path的host转为hex编码
+// url.Parse不允许host中包含%2F, 编码后每个socket的连接池互相独立
+func (req *Request) rewriteSocketURL(rawURL string) (string, error) {
+	i := strings.Index(rawURL, "://")
+	if i < 0 || req.socketScheme(rawURL[:i]) == "" {
+		return rawURL, nil
+	}
+	rest := rawURL[i+3:]
+	end := strings.IndexAny(rest, "/?#")
+	if end < 0 {
+		end = len(rest)
+	}
+	address, err := url.PathUnescape(rest[:end])
+	if err != nil {
+		return "", fmt.Errorf("httpclient: invalid socket address %q: %s", rest[:end], err)
+	}
+	if address == "" {
+		return "", fmt.Errorf("httpclient: empty socket address in %q", rawURL)
+	}
+
+	return rawURL[:i+3] + hex.EncodeToString([]byte(address)) + rest[end:], nil
+}
+
+type socketTransport struct {
+	trans *http.Transport
+}
+
+func newSocketTransport(base *http.Transport, dial SocketDialFunc) *socketTransport {
+	trans := base.Clone()
+	trans.Proxy = nil
+	trans.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		address, err := hex.DecodeString(host)
+		if err != nil {
+			return nil, fmt.Errorf("httpclient: invalid socket host %q", host)
+		}
+
+		return dial(ctx, string(address))
+	}
+
+	return &socketTransport{trans: trans}
+}
+
+func (t *socketTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	target := r.Clone(r.Context())
+	u := *r.URL
+	u.Scheme = "http"
+	target.URL = &u
+	if target.Host == "" || target.Host == r.URL.Host {
+		target.Host = "localhost"
+	}
+	resp, err := t.trans.RoundTrip(target)
+	if resp != nil {
+		// 相对跳转基于原始地址解析
+		resp.Request = r
+	}
+
+	return resp, err
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// 在address上启动返回name、path和Host的服务
+func startSocketServer(t *testing.T, network, address, name string) *httptest.Server {
+	l, err := net.Listen(network, address)
+	require.NoError(t, err)
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/redirect" {
+			http.Redirect(rw, req, "/target", http.StatusFound)
+			return
+		}
+		_, _ = fmt.Fprintf(rw, "%s %s?%s %s", name, req.URL.Path, req.URL.RawQuery, req.Host)
+	}))
+	s.Listener = l
+	s.Start()
+
+	return s
+}
+
+func TestRequest_UnixSocketURL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpclient")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socketA := filepath.Join(dir, "a.sock")
+	socketB := filepath.Join(dir, "b.sock")
+	a := startSocketServer(t, "unix", socketA, "a")
+	defer a.Close()
+	b := startSocketServer(t, "unix", socketB, "b")
+	defer b.Close()
+
+	req := NewRequest()
+	resp, err := req.Get("http+unix://"+url.PathEscape(socketA)+"/containers/json", url.Values{"all": {"1"}}, nil)
+	require.NoError(t, err)
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "a /containers/json?all=1 localhost", body)
+
+	resp, err = req.Get("http+unix://"+url.PathEscape(socketB)+"/info", nil, nil)
+	require.NoError(t, err)
+	body, err = resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "b /info? localhost", body)
+
+	resp, err = req.Get("http+unix://"+url.PathEscape(socketA)+"/redirect", nil, nil)
+	require.NoError(t, err)
+	body, err = resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "a /target? localhost", body)
+
+	_, err = req.Get("http+unix:///path", nil, nil)
+	require.Error(t, err)
+}
+
+func TestRequest_AbstractUnixSocketURL(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("abstract unix socket requires linux")
+	}
+	name := fmt.Sprintf("@httpclient-test-%d", time.Now().UnixNano())
+	s := startSocketServer(t, "unix", name, "abstract")
+	defer s.Close()
+
+	resp, err := NewRequest().Get("http+unix://"+url.PathEscape(name)+"/ping", nil, nil)
+	require.NoError(t, err)
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "abstract /ping? localhost", body)
+}
+
+func TestWithSocketDialer(t *testing.T) {
+	s := startSocketServer(t, "tcp", "127.0.0.1:0", "vsock")
+	defer s.Close()
+
+	var dialed string
+	dial := func(ctx context.Context, address string) (net.Conn, error) {
+		dialed = address
+		return (&net.Dialer{}).DialContext(ctx, "tcp", s.Listener.Addr().String())
+	}
+	req := NewRequest(WithSocketDialer("vsock", dial))
+	header := make(http.Header)
+	header.Set("Host", "guest")
+	resp, err := req.Get("http+vsock://3:8080/health", nil, header)
+	require.NoError(t, err)
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "vsock /health? guest", body)
+	require.Equal(t, "3:8080", dialed)
+
+	_, err = req.Get("http+unknown://x/health", nil, nil)
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "unsupported protocol scheme"))
+}
+
+func TestRequest_rewriteSocketURL(t *testing.T) {
+	req := NewRequest()
+	u, err := req.rewriteSocketURL("http+unix://%2Fvar%2Frun%2Fdocker.sock/containers/json?all=1")
+	require.NoError(t, err)
+	require.Equal(t, "http+unix://2f7661722f72756e2f646f636b65722e736f636b/containers/json?all=1", u)
+
+	u, err = req.rewriteSocketURL("https://golang.org/x")
+	require.NoError(t, err)
+	require.Equal(t, "https://golang.org/x", u)
+
+	_, err = req.rewriteSocketURL("http+unix://%zz/x")
+	require.Error(t, err)
+}
@@ -0,0 +1,274 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+)
+
+// Stats 连接池统计快照
+type Stats struct {
+	Hosts []*HostStats `json:"hosts"`
+}
+
+// HostStats 单个host的连接统计, 计数从Request创建开始累计
+// 空闲、活跃连接数和建立连接次数只统计Request创建的默认Transport
+type HostStats struct {
+	Host               string  `json:"host"`
+	IdleConns          int     `json:"idle_conns"`
+	ActiveConns        int     `json:"active_conns"`
+	GotConns           int64   `json:"got_conns"`
+	ReusedConns        int64   `json:"reused_conns"`
+	ReuseRatio         float64 `json:"reuse_ratio"`
+	Dials              int64   `json:"dials"`
+	DialErrors         int64   `json:"dial_errors"`
+	TLSHandshakes      int64   `json:"tls_handshakes"`
+	TLSHandshakeErrors int64   `json:"tls_handshake_errors"`
+}
+
+// Host 查找host的统计, host格式为host:port
+func (s *Stats) Host(host string) *HostStats {
+	for _, h := range s.Hosts {
+		if h.Host == host {
+			return h
+		}
+	}
+
+	return nil
+}
+
+// Stats 连接池统计快照
+func (req *Request) Stats() *Stats {
+	return req.stats.snapshot()
+}
+
+// StatsHandler 以JSON输出连接池统计的调试handler
+func (req *Request) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", MediaTypeJSON)
+		enc := json.NewEncoder(rw)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(req.Stats())
+	})
+}
+
+type hostCounters struct {
+	idle, active       int
+	gotConns, reused   int64
+	dials, dialErrors  int64
+	handshakes, tlsErr int64
+}
+
+type connStats struct {
+	mu    sync.Mutex
+	hosts map[string]*hostCounters
+}
+
+func newConnStats() *connStats {
+	return &connStats{hosts: make(map[string]*hostCounters)}
+}
+
+// 调用方需持有锁
+func (s *connStats) host(host string) *hostCounters {
+	h, ok := s.hosts[host]
+	if !ok {
+		h = &hostCounters{}
+		s.hosts[host] = h
+	}
+
+	return h
+}
+
+func (s *connStats) snapshot() *Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := &Stats{Hosts: make([]*HostStats, 0, len(s.hosts))}
+	for host, h := range s.hosts {
+		hs := &HostStats{
+			Host:               host,
+			IdleConns:          h.idle,
+			ActiveConns:        h.active,
+			GotConns:           h.gotConns,
+			ReusedConns:        h.reused,
+			Dials:              h.dials,
+			DialErrors:         h.dialErrors,
+			TLSHandshakes:      h.handshakes,
+			TLSHandshakeErrors: h.tlsErr,
+		}
+		if h.gotConns > 0 {
+			hs.ReuseRatio = float64(h.reused) / float64(h.gotConns)
+		}
+		stats.Hosts = append(stats.Hosts, hs)
+	}
+	sort.Slice(stats.Hosts, func(i, j int) bool {
+		return stats.Hosts[i].Host < stats.Hosts[j].Host
+	})
+
+	return stats
+}
+
+// wrapDial 统计建立连接, 连接关闭时减少连接数
+func (s *connStats) wrapDial(dial DialContext) DialContext {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		h := s.host(addr)
+		h.dials++
+		if err != nil {
+			h.dialErrors++
+			return nil, err
+		}
+		h.idle++
+
+		return &statsConn{Conn: conn, stats: s, host: addr}, nil
+	}
+}
+
+// wrap 每次RoundTrip使用单独的ClientTrace, 对冲请求共享context时也能区分连接
+func (s *connStats) wrap(rt http.RoundTripper) http.RoundTripper {
+	return &statsTransport{base: rt, stats: s}
+}
+
+type statsTransport struct {
+	base  http.RoundTripper
+	stats *connStats
+}
+
+func (t *statsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	host := canonicalAddr(r)
+	var conn *statsConn
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.stats.mu.Lock()
+			defer t.stats.mu.Unlock()
+			h := t.stats.host(host)
+			h.gotConns++
+			if info.Reused {
+				h.reused++
+			}
+			conn = unwrapStatsConn(info.Conn)
+			if conn != nil && !conn.closed && !conn.active {
+				conn.active = true
+				t.stats.host(conn.host).idle--
+				t.stats.host(conn.host).active++
+			}
+		},
+		PutIdleConn: func(err error) {
+			t.stats.mu.Lock()
+			defer t.stats.mu.Unlock()
+			if err == nil && conn != nil {
+				conn.idleLocked()
+			}
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			t.stats.mu.Lock()
+			defer t.stats.mu.Unlock()
+			h := t.stats.host(host)
+			h.handshakes++
+			if err != nil {
+				h.tlsErr++
+			}
+		},
+	}
+	resp, err := t.base.RoundTrip(r.WithContext(httptrace.WithClientTrace(r.Context(), trace)))
+	if resp != nil {
+		resp.Request = r
+	}
+
+	return resp, err
+}
+
+func (t *statsTransport) unwrap() http.RoundTripper {
+	return t.base
+}
+
+// baseTransport 去除内部包装, 返回实际的Transport
+func baseTransport(rt http.RoundTripper) http.RoundTripper {
+	for {
+		w, ok := rt.(interface{ unwrap() http.RoundTripper })
+		if !ok {
+			return rt
+		}
+		rt = w.unwrap()
+	}
+}
+
+type statsConn struct {
+	net.Conn
+	stats *connStats
+	host  string
+	// 以下字段由stats.mu保护
+	active bool
+	closed bool
+}
+
+// idleLocked 连接放回连接池, 调用方需持有锁
+func (c *statsConn) idleLocked() {
+	if c.closed || !c.active {
+		return
+	}
+	c.active = false
+	h := c.stats.host(c.host)
+	h.active--
+	h.idle++
+}
+
+func (c *statsConn) Close() error {
+	c.stats.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		h := c.stats.host(c.host)
+		if c.active {
+			h.active--
+		} else {
+			h.idle--
+		}
+	}
+	c.stats.mu.Unlock()
+
+	return c.Conn.Close()
+}
+
+// unwrapStatsConn https连接为*tls.Conn, 通过NetConn获取底层连接
+func unwrapStatsConn(conn net.Conn) *statsConn {
+	if c, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = c.NetConn()
+	}
+	c, _ := conn.(*statsConn)
+
+	return c
+}
+
+// canonicalAddr host:port, 未指定端口时使用协议默认端口
+func canonicalAddr(r *http.Request) string {
+	host := r.URL.Host
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	port := "80"
+	if r.URL.Scheme == "https" || r.URL.Scheme == "wss" {
+		port = "443"
+	}
+
+	return net.JoinHostPort(r.URL.Hostname(), port)
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_Stats(t *testing.T) {
+	block := make(chan struct{})
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("block") != "" {
+			<-block
+		}
+		_, _ = io.WriteString(rw, "ok")
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+	host := s.Listener.Addr().String()
+
+	req := NewRequest()
+	for i := 0; i < 3; i++ {
+		resp, err := req.Get(s.URL, nil, nil)
+		require.NoError(t, err)
+		_, err = resp.Bytes()
+		require.NoError(t, err)
+	}
+	stats := req.Stats().Host(host)
+	require.NotNil(t, stats)
+	require.EqualValues(t, 1, stats.Dials)
+	require.EqualValues(t, 3, stats.GotConns)
+	require.EqualValues(t, 2, stats.ReusedConns)
+	require.InDelta(t, 2.0/3, stats.ReuseRatio, 0.001)
+	require.Equal(t, 1, stats.IdleConns)
+	require.Equal(t, 0, stats.ActiveConns)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err := req.Get(s.URL+"?block=1", nil, nil)
+		if err == nil {
+			_, _ = resp.Bytes()
+		}
+	}()
+	require.Eventually(t, func() bool {
+		return req.Stats().Host(host).ActiveConns == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, 0, req.Stats().Host(host).IdleConns)
+	close(block)
+	<-done
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closed := l.Addr().String()
+	require.NoError(t, l.Close())
+	_, err = req.Get("http://"+closed, nil, nil)
+	require.Error(t, err)
+	require.EqualValues(t, 1, req.Stats().Host(closed).DialErrors)
+
+	rec := httptest.NewRecorder()
+	req.StatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/httpclient", nil))
+	require.Equal(t, MediaTypeJSON, rec.Header().Get("Content-Type"))
+	decoded := &Stats{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), decoded))
+	require.EqualValues(t, 4, decoded.Host(host).GotConns)
+
+	m := NewMetric("stats_test", "", nil)
+	m.RegisterStats("default", req)
+	var conns, dialErrors string
+	for _, h := range req.Stats().Hosts {
+		conns += fmt.Sprintf("stats_test_http_client_connections{client=\"default\",host=%q,state=\"active\"} %d\n", h.Host, h.ActiveConns)
+		conns += fmt.Sprintf("stats_test_http_client_connections{client=\"default\",host=%q,state=\"idle\"} %d\n", h.Host, h.IdleConns)
+		dialErrors += fmt.Sprintf("stats_test_http_client_dial_errors_total{client=\"default\",host=%q} %d\n", h.Host, h.DialErrors)
+	}
+	expected := `
+# HELP stats_test_http_client_connections http client open connections
+# TYPE stats_test_http_client_connections gauge
+` + conns + `# HELP stats_test_http_client_dial_errors_total http client dial errors total
+# TYPE stats_test_http_client_dial_errors_total counter
+` + dialErrors
+	require.NoError(t, testutil.GatherAndCompare(prometheus.DefaultGatherer, strings.NewReader(expected),
+		"stats_test_http_client_connections", "stats_test_http_client_dial_errors_total"))
+}
+
+func TestRequest_Stats_TLS(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer s.Close()
+
+	req := NewRequest(WithClient(s.Client()))
+	for i := 0; i < 2; i++ {
+		resp, err := req.Get(s.URL, nil, nil)
+		require.NoError(t, err)
+		_, err = resp.Bytes()
+		require.NoError(t, err)
+	}
+	stats := req.Stats().Host(s.Listener.Addr().String())
+	require.EqualValues(t, 1, stats.TLSHandshakes)
+	require.EqualValues(t, 0, stats.TLSHandshakeErrors)
+	require.EqualValues(t, 1, stats.ReusedConns)
+}
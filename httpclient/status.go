@@ -0,0 +1,63 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// StatusError 响应状态码不是2xx
+type StatusError struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("httpclient: unexpected status %d", e.StatusCode)
+}
+
+// IsStatusSuccess 响应码是否为2xx
+func (resp *Response) IsStatusSuccess() bool {
+	return resp.rawResp.StatusCode >= 200 && resp.rawResp.StatusCode < 300
+}
+
+// CheckStatus 响应码不是2xx时读取body并返回错误, 2xx时返回nil, body未读取
+// target非nil时按Content-Type对应的codec(默认JSON)将body解码到target并返回target, 解码失败或body为空时返回*StatusError
+func CheckStatus(resp *Response, target error) error {
+	if resp.IsStatusSuccess() {
+		return nil
+	}
+	body, err := resp.Bytes()
+	if err != nil {
+		return err
+	}
+	if target != nil && len(body) > 0 {
+		contentType := resp.rawResp.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = MediaTypeJSON
+		}
+		if codec, ok := LookupCodec(contentType); ok && codec.Unmarshal(body, target) == nil {
+			return target
+		}
+	}
+
+	return &StatusError{
+		StatusCode: resp.rawResp.StatusCode,
+		Header:     resp.rawResp.Header,
+		Body:       body,
+	}
+}
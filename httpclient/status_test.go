@@ -0,0 +1,73 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testAPIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *testAPIError) Error() string {
+	return e.Message
+}
+
+func TestCheckStatus(t *testing.T) {
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/ok":
+			rw.WriteHeader(http.StatusCreated)
+			_, _ = io.WriteString(rw, "created")
+		case "/json":
+			rw.Header().Set("Content-Type", MediaTypeJSON)
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(rw, `{"code":1001,"message":"invalid name"}`)
+		default:
+			rw.WriteHeader(http.StatusBadGateway)
+			_, _ = io.WriteString(rw, "<html>bad gateway</html>")
+		}
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest()
+	resp, err := req.Get(s.URL+"/ok", nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, CheckStatus(resp, &testAPIError{}))
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "created", body)
+
+	resp, err = req.Get(s.URL+"/json", nil, nil)
+	require.NoError(t, err)
+	err = CheckStatus(resp, &testAPIError{})
+	require.Equal(t, &testAPIError{Code: 1001, Message: "invalid name"}, err)
+
+	resp, err = req.Get(s.URL+"/html", nil, nil)
+	require.NoError(t, err)
+	err = CheckStatus(resp, &testAPIError{})
+	statusErr, ok := err.(*StatusError)
+	require.True(t, ok)
+	require.Equal(t, http.StatusBadGateway, statusErr.StatusCode)
+	require.Equal(t, "<html>bad gateway</html>", string(statusErr.Body))
+}
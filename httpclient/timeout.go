@@ -0,0 +1,46 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"time"
+)
+
+// WithAttemptTimeout 单次尝试超时, 包括读取body, 每次重试重新计时, 同时受WithTimeout限制
+func WithAttemptTimeout(d time.Duration) Option {
+	return func(opt *options) {
+		opt.attemptTimeout = d
+	}
+}
+
+// WithTotalTimeout 整个调用的超时, 包括所有重试、退避等待和读取body
+// 剩余时间不足以完成下一次尝试时不再重试, 返回最后一次的结果
+func WithTotalTimeout(d time.Duration) Option {
+	return func(opt *options) {
+		opt.totalTimeout = d
+	}
+}
+
+// canRetryWithin 等待wait后再用fastest完成一次尝试是否会超过ctx的deadline
+// fastest为已完成尝试中的最短耗时, 作为下一次尝试耗时的下限估计
+func canRetryWithin(ctx context.Context, wait, fastest time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return true
+	}
+
+	return time.Until(deadline) > wait+fastest
+}
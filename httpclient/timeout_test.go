@@ -0,0 +1,125 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_WithAttemptTimeout(t *testing.T) {
+	var attempts int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			select {
+			case <-req.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		_, _ = io.WriteString(rw, "ok")
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest(WithAttemptTimeout(50*time.Millisecond), WithRetryTime(1))
+	resp, err := req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "ok", body)
+	require.EqualValues(t, 2, atomic.LoadInt32(&attempts))
+}
+
+func TestRequest_WithTotalTimeout(t *testing.T) {
+	var attempts int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(rw, "busy")
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	// 第一次退避600ms后重试, 第二次退避1.2s超出剩余时间, 不再重试
+	req := NewRequest(WithTotalTimeout(time.Second), WithRetryTime(5))
+	start := time.Now()
+	resp, err := req.Get(s.URL, nil, nil)
+	require.NoError(t, err)
+	require.True(t, time.Since(start) < time.Second)
+	require.EqualValues(t, 2, atomic.LoadInt32(&attempts))
+	require.Equal(t, http.StatusServiceUnavailable, resp.Raw().StatusCode)
+	// 返回后body仍可读取
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "busy", body)
+}
+
+func TestRequest_WithTotalTimeout_SlowAttempt(t *testing.T) {
+	var attempts int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		select {
+		case <-req.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	req := NewRequest(WithTotalTimeout(100*time.Millisecond), WithRetryTime(3))
+	start := time.Now()
+	_, err := req.Get(s.URL, nil, nil)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.True(t, time.Since(start) < 500*time.Millisecond)
+	require.EqualValues(t, 1, atomic.LoadInt32(&attempts))
+}
+
+func TestRequest_RetryBackoffCanceled(t *testing.T) {
+	var attempts int32
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	// 退避600ms期间取消, 立即返回
+	req := NewRequest(WithRetryTime(3))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := req.Do(ctx, http.MethodGet, s.URL, nil, nil)
+	require.True(t, errors.Is(err, context.Canceled))
+	require.True(t, time.Since(start) < 500*time.Millisecond)
+	require.EqualValues(t, 1, atomic.LoadInt32(&attempts))
+}
+
+func TestCanRetryWithin(t *testing.T) {
+	require.True(t, canRetryWithin(context.Background(), time.Hour, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.True(t, canRetryWithin(ctx, 300*time.Millisecond, 100*time.Millisecond))
+	require.False(t, canRetryWithin(ctx, 600*time.Millisecond, 500*time.Millisecond))
+}
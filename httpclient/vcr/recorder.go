@@ -0,0 +1,342 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Package vcr 录制回放http请求, 用于测试
+package vcr
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Mode 运行模式
+type Mode int
+
+const (
+	// ModeReplay 只回放, 找不到匹配的记录返回错误
+	ModeReplay Mode = iota
+	// ModeRecord 请求真实服务并录制, 覆盖已有记录
+	ModeRecord
+	// ModeReplayOrRecord 优先回放, 找不到匹配的记录时请求真实服务并追加录制
+	ModeReplayOrRecord
+)
+
+// RedactedValue 脱敏后的值
+const RedactedValue = "[REDACTED]"
+
+// Matcher 判断请求是否匹配录制的记录, body为请求body脱敏后的内容
+type Matcher func(r *http.Request, body []byte, i *Interaction) bool
+
+// MatchMethod 匹配请求方法
+func MatchMethod(r *http.Request, body []byte, i *Interaction) bool {
+	return r.Method == i.Request.Method
+}
+
+// MatchURL 匹配完整URL
+func MatchURL(r *http.Request, body []byte, i *Interaction) bool {
+	return r.URL.String() == i.Request.URL
+}
+
+// MatchBody 匹配请求body
+func MatchBody(r *http.Request, body []byte, i *Interaction) bool {
+	recorded, err := decodeBody(i.Request.Body, i.Request.BodyEncoding)
+
+	return err == nil && bytes.Equal(body, recorded)
+}
+
+// MissingInteractionError 找不到匹配的录制记录
+type MissingInteractionError struct {
+	Cassette string
+	Method   string
+	URL      string
+}
+
+func (e *MissingInteractionError) Error() string {
+	return fmt.Sprintf("vcr: cassette %s has no interaction for %s %s", e.Cassette, e.Method, e.URL)
+}
+
+type options struct {
+	transport     http.RoundTripper
+	matchers      []Matcher
+	redactHeaders []string
+	redactBody    func([]byte) []byte
+	allowRepeat   bool
+}
+
+// Option 可选参数
+type Option func(*options)
+
+// WithRealTransport 录制时使用的真实transport, 默认http.DefaultTransport
+func WithRealTransport(t http.RoundTripper) Option {
+	return func(opt *options) {
+		opt.transport = t
+	}
+}
+
+// WithMatchers 回放时的匹配规则, 默认匹配method和URL
+func WithMatchers(m ...Matcher) Option {
+	return func(opt *options) {
+		opt.matchers = m
+	}
+}
+
+// WithRedactHeaders 保存时需脱敏的header, 请求和响应都会处理
+func WithRedactHeaders(keys ...string) Option {
+	return func(opt *options) {
+		opt.redactHeaders = append(opt.redactHeaders, keys...)
+	}
+}
+
+// WithRedactBody body脱敏, 保存前和回放匹配前都会调用
+func WithRedactBody(f func([]byte) []byte) Option {
+	return func(opt *options) {
+		opt.redactBody = f
+	}
+}
+
+// WithAllowRepeat 允许一条记录被多次回放, 默认每条记录只回放一次
+func WithAllowRepeat() Option {
+	return func(opt *options) {
+		opt.allowRepeat = true
+	}
+}
+
+// Recorder 录制回放transport
+type Recorder struct {
+	opts     options
+	filename string
+	mode     Mode
+	mu       sync.Mutex
+	cassette *Cassette
+	used     map[int]bool
+	changed  bool
+}
+
+// New 创建Recorder, 回放模式下cassette文件必须存在
+func New(filename string, mode Mode, opt ...Option) (*Recorder, error) {
+	r := &Recorder{
+		filename: filename,
+		mode:     mode,
+		used:     make(map[int]bool),
+	}
+	for _, o := range opt {
+		o(&r.opts)
+	}
+	if r.opts.transport == nil {
+		r.opts.transport = http.DefaultTransport
+	}
+	if len(r.opts.matchers) == 0 {
+		r.opts.matchers = []Matcher{MatchMethod, MatchURL}
+	}
+
+	switch mode {
+	case ModeRecord:
+		r.cassette = &Cassette{}
+	case ModeReplay:
+		c, err := LoadCassette(filename)
+		if err != nil {
+			return nil, err
+		}
+		r.cassette = c
+	case ModeReplayOrRecord:
+		c, err := LoadCassette(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if c == nil {
+			c = &Cassette{}
+		}
+		r.cassette = c
+	default:
+		return nil, fmt.Errorf("vcr: invalid mode %d", mode)
+	}
+
+	return r, nil
+}
+
+// RoundTrip 实现http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	// 不修改调用方的请求
+	req = req.Clone(req.Context())
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode != ModeRecord {
+		if i := r.match(req, r.redactedBody(body)); i != nil {
+			return i.Response.toHTTP(req)
+		}
+		if r.mode == ModeReplay {
+			return nil, &MissingInteractionError{
+				Cassette: r.filename,
+				Method:   req.Method,
+				URL:      req.URL.String(),
+			}
+		}
+	}
+
+	return r.record(req, body)
+}
+
+// Stop 录制模式下保存cassette文件
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.changed {
+		return nil
+	}
+	r.changed = false
+
+	return r.cassette.Save(r.filename)
+}
+
+// Cassette 获取当前cassette的副本
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return &Cassette{
+		Version:      r.cassette.Version,
+		Interactions: append([]*Interaction(nil), r.cassette.Interactions...),
+	}
+}
+
+func (r *Recorder) match(req *http.Request, body []byte) *Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for idx, i := range r.cassette.Interactions {
+		if r.used[idx] && !r.opts.allowRepeat {
+			continue
+		}
+		if r.matchAll(req, body, i) {
+			r.used[idx] = true
+			return i
+		}
+	}
+
+	return nil
+}
+
+func (r *Recorder) matchAll(req *http.Request, body []byte, i *Interaction) bool {
+	for _, m := range r.opts.matchers {
+		if !m(req, body, i) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.opts.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	i := &Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: r.redactedHeader(req.Header),
+		},
+		Response: RecordedResponse{
+			Status:     resp.Status,
+			StatusCode: resp.StatusCode,
+			Header:     r.redactedHeader(resp.Header),
+		},
+	}
+	i.Request.Body, i.Request.BodyEncoding = encodeBody(r.redactedBody(body))
+	i.Response.Body, i.Response.BodyEncoding = encodeBody(r.redactedBody(respBody))
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+	r.used[len(r.cassette.Interactions)-1] = true
+	r.changed = true
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+func (r *Recorder) redactedHeader(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	h = h.Clone()
+	for _, key := range r.opts.redactHeaders {
+		if _, ok := h[http.CanonicalHeaderKey(key)]; ok {
+			h.Set(key, RedactedValue)
+		}
+	}
+
+	return h
+}
+
+func (r *Recorder) redactedBody(body []byte) []byte {
+	if r.opts.redactBody == nil || len(body) == 0 {
+		return body
+	}
+
+	return r.opts.redactBody(body)
+}
+
+// 读取请求body并重置, 以便真实请求时可再次读取, req为RoundTrip中复制的请求
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+func (resp RecordedResponse) toHTTP(req *http.Request) (*http.Response, error) {
+	body, err := decodeBody(resp.Body, resp.BodyEncoding)
+	if err != nil {
+		return nil, err
+	}
+	header := resp.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	status := resp.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	return &http.Response{
+		Status:        status,
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
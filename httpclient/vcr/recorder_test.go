@@ -0,0 +1,210 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package vcr
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ouqiang/goutil/httpclient"
+)
+
+func TestRecorder_RecordAndReplay(t *testing.T) {
+	for _, name := range []string{"cassette.json", "cassette.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			testRecordAndReplay(t, name)
+		})
+	}
+}
+
+func testRecordAndReplay(t *testing.T, name string) {
+	dir, err := ioutil.TempDir("", "vcr")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, name)
+
+	calls := 0
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		rw.Header().Set("Set-Cookie", "session=secret")
+		_, _ = io.Copy(rw, req.Body)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	redactBody := func(b []byte) []byte {
+		return bytes.Replace(b, []byte("password"), []byte(RedactedValue), -1)
+	}
+	r, err := New(filename, ModeRecord,
+		WithRedactHeaders("Authorization", "Set-Cookie"),
+		WithRedactBody(redactBody),
+		WithMatchers(MatchMethod, MatchURL, MatchBody),
+	)
+	require.NoError(t, err)
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer token")
+	req := httpclient.NewRequest(httpclient.WithTransport(r))
+	resp, err := req.Post(s.URL, "name=golang&password", header)
+	require.NoError(t, err)
+	body, err := resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "name=golang&password", body)
+	require.NoError(t, r.Stop())
+	require.Equal(t, 1, calls)
+
+	c, err := LoadCassette(filename)
+	require.NoError(t, err)
+	require.Len(t, c.Interactions, 1)
+	require.Equal(t, RedactedValue, c.Interactions[0].Request.Header.Get("Authorization"))
+	require.Equal(t, RedactedValue, c.Interactions[0].Response.Header.Get("Set-Cookie"))
+	require.Equal(t, "name=golang&"+RedactedValue, c.Interactions[0].Response.Body)
+
+	r, err = New(filename, ModeReplay,
+		WithRedactBody(redactBody),
+		WithMatchers(MatchMethod, MatchURL, MatchBody),
+	)
+	require.NoError(t, err)
+	req = httpclient.NewRequest(httpclient.WithTransport(r))
+	resp, err = req.Post(s.URL, "name=golang&password", nil)
+	require.NoError(t, err)
+	require.True(t, resp.IsStatusOK())
+	body, err = resp.String()
+	require.NoError(t, err)
+	require.Equal(t, "name=golang&"+RedactedValue, body)
+	require.Equal(t, 1, calls)
+
+	_, err = req.Post(s.URL, "name=golang&password", nil)
+	var missing *MissingInteractionError
+	require.True(t, errors.As(err, &missing))
+	require.Equal(t, http.MethodPost, missing.Method)
+	require.Equal(t, s.URL, missing.URL)
+}
+
+func TestRecorder_ReplayOrRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vcr")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "cassette.json")
+
+	calls := 0
+	handler := func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		_, _ = io.WriteString(rw, req.URL.Path)
+	}
+	s := httptest.NewServer(http.HandlerFunc(handler))
+	defer s.Close()
+
+	_, err = New(filename, ModeReplay)
+	require.True(t, os.IsNotExist(err))
+
+	r, err := New(filename, ModeReplayOrRecord, WithAllowRepeat())
+	require.NoError(t, err)
+	req := httpclient.NewRequest(httpclient.WithTransport(r))
+	for i := 0; i < 3; i++ {
+		resp, err := req.Get(s.URL+"/a", nil, nil)
+		require.NoError(t, err)
+		body, err := resp.String()
+		require.NoError(t, err)
+		require.Equal(t, "/a", body)
+	}
+	require.Equal(t, 1, calls)
+	require.NoError(t, r.Stop())
+	require.Len(t, r.Cassette().Interactions, 1)
+}
+
+func TestRecorder_BinaryBody(t *testing.T) {
+	for _, name := range []string{"cassette.json", "cassette.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "vcr")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			filename := filepath.Join(dir, name)
+
+			s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				_, _ = io.Copy(rw, req.Body)
+			}))
+			defer s.Close()
+
+			payload := []byte{0x1f, 0x8b, 0x00, 0xff, 0xfe}
+			r, err := New(filename, ModeRecord)
+			require.NoError(t, err)
+			req := httpclient.NewRequest(httpclient.WithTransport(r))
+			_, err = req.Post(s.URL, payload, nil)
+			require.NoError(t, err)
+			require.NoError(t, r.Stop())
+
+			c, err := LoadCassette(filename)
+			require.NoError(t, err)
+			require.Equal(t, BodyEncodingBase64, c.Interactions[0].Request.BodyEncoding)
+			require.Equal(t, BodyEncodingBase64, c.Interactions[0].Response.BodyEncoding)
+
+			r, err = New(filename, ModeReplay, WithMatchers(MatchMethod, MatchURL, MatchBody))
+			require.NoError(t, err)
+			req = httpclient.NewRequest(httpclient.WithTransport(r))
+			resp, err := req.Post(s.URL, payload, nil)
+			require.NoError(t, err)
+			body, err := resp.Bytes()
+			require.NoError(t, err)
+			require.Equal(t, payload, body)
+		})
+	}
+}
+
+func TestRecorder_RoundTripConcurrent(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(rw, "ok")
+	}))
+	defer s.Close()
+
+	r, err := New(filepath.Join(os.TempDir(), "vcr-concurrent.json"), ModeRecord)
+	require.NoError(t, err)
+	// RoundTrip不修改调用方的请求
+	body := ioutil.NopCloser(bytes.NewReader([]byte("data")))
+	original, err := http.NewRequest(http.MethodPost, s.URL, body)
+	require.NoError(t, err)
+	resp, err := r.RoundTrip(original)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.True(t, original.Body == body)
+
+	var wg sync.WaitGroup
+	req := httpclient.NewRequest(httpclient.WithTransport(r))
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := req.Get(s.URL, nil, nil)
+			errs <- err
+		}()
+		_ = r.Cassette()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.Len(t, r.Cassette().Interactions, 6)
+}
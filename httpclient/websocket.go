@@ -0,0 +1,200 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket消息类型
+const (
+	TextMessage   = websocket.TextMessage
+	BinaryMessage = websocket.BinaryMessage
+)
+
+const websocketWriteWait = 10 * time.Second
+
+// WebSocketHandshakeError 握手失败, 服务端未返回101
+type WebSocketHandshakeError struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+func (e *WebSocketHandshakeError) Error() string {
+	return fmt.Sprintf("httpclient: websocket handshake failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// WithWebSocketPingInterval 定时发送ping, 读取时超过2个周期未收到消息或pong返回超时错误
+func WithWebSocketPingInterval(interval time.Duration) Option {
+	return func(opt *options) {
+		opt.websocketPingInterval = interval
+	}
+}
+
+// WithWebSocketCompression 协商permessage-deflate压缩
+func WithWebSocketCompression() Option {
+	return func(opt *options) {
+		opt.websocketCompression = true
+	}
+}
+
+// WebSocketConn 消息模式的WebSocket连接, 读取只允许一个goroutine调用, 写入可并发
+type WebSocketConn struct {
+	conn         *websocket.Conn
+	pingInterval time.Duration
+	writeMu      sync.Mutex
+	closeOnce    sync.Once
+	done         chan struct{}
+}
+
+// DialWebSocket 建立WebSocket连接, url为ws://或wss://
+// 复用Request的dns解析、unix socket、代理、TLS配置和cookie jar
+func (req *Request) DialWebSocket(ctx context.Context, url string, header http.Header) (*WebSocketConn, error) {
+	dialer := &websocket.Dialer{
+		NetDialContext:    req.dial,
+		Proxy:             req.proxy,
+		HandshakeTimeout:  req.opts.timeout,
+		EnableCompression: req.opts.websocketCompression,
+		Jar:               req.opts.client.Jar,
+	}
+	if trans, ok := baseTransport(req.opts.client.Transport).(*http.Transport); ok && trans.TLSClientConfig != nil {
+		dialer.TLSClientConfig = trans.TLSClientConfig.Clone()
+	}
+	conn, resp, err := dialer.DialContext(ctx, url, header.Clone())
+	if err != nil {
+		if err == websocket.ErrBadHandshake && resp != nil {
+			body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+			_ = resp.Body.Close()
+			return nil, &WebSocketHandshakeError{
+				StatusCode: resp.StatusCode,
+				Header:     resp.Header,
+				Body:       string(body),
+			}
+		}
+		return nil, err
+	}
+
+	c := &WebSocketConn{
+		conn:         conn,
+		pingInterval: req.opts.websocketPingInterval,
+		done:         make(chan struct{}),
+	}
+	if c.pingInterval > 0 {
+		c.keepalive()
+	}
+
+	return c, nil
+}
+
+// ReadMessage 读取一条完整消息
+func (c *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	if err = c.extendReadDeadline(); err != nil {
+		return 0, nil, err
+	}
+
+	return c.conn.ReadMessage()
+}
+
+// WriteMessage 发送一条消息, 可并发调用
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// ReadJSON 读取一条消息并解析json
+func (c *WebSocketConn) ReadJSON(v interface{}) error {
+	if err := c.extendReadDeadline(); err != nil {
+		return err
+	}
+
+	return c.conn.ReadJSON(v)
+}
+
+// WriteJSON 编码为json后作为文本消息发送, 可并发调用
+func (c *WebSocketConn) WriteJSON(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.conn.WriteJSON(v)
+}
+
+// Subprotocol 协商的子协议
+func (c *WebSocketConn) Subprotocol() string {
+	return c.conn.Subprotocol()
+}
+
+// Raw 底层连接
+func (c *WebSocketConn) Raw() *websocket.Conn {
+	return c.conn
+}
+
+// Close 发送close帧后关闭连接
+func (c *WebSocketConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.writeMu.Lock()
+		_ = c.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			time.Now().Add(websocketWriteWait))
+		c.writeMu.Unlock()
+		err = c.conn.Close()
+	})
+
+	return err
+}
+
+// 开启keepalive时每次读取前重置deadline, 读取阻塞期间收到pong时延长
+func (c *WebSocketConn) extendReadDeadline() error {
+	if c.pingInterval <= 0 {
+		return nil
+	}
+
+	return c.conn.SetReadDeadline(time.Now().Add(2 * c.pingInterval))
+}
+
+func (c *WebSocketConn) keepalive() {
+	c.conn.SetPongHandler(func(string) error {
+		return c.extendReadDeadline()
+	})
+	go func() {
+		ticker := time.NewTicker(c.pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+				c.writeMu.Lock()
+				err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(websocketWriteWait))
+				c.writeMu.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,155 @@
+// Copyright 2018 ouqiang authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// 回显消息, 通过pings统计收到的ping
+func newWebSocketEchoHandler(pings *int32) http.HandlerFunc {
+	upgrader := websocket.Upgrader{EnableCompression: true}
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Upgrade") == "" {
+			http.Error(rw, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, err := upgrader.Upgrade(rw, req, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetPingHandler(func(data string) error {
+			if pings != nil {
+				atomic.AddInt32(pings, 1)
+			}
+			return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if messageType == websocket.TextMessage && string(data) == "cookie" {
+				cookie, _ := req.Cookie("session")
+				if cookie != nil {
+					data = []byte(cookie.Value)
+				}
+			}
+			if err = conn.WriteMessage(messageType, data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func wsURL(httpURL string) string {
+	return "ws" + strings.TrimPrefix(httpURL, "http")
+}
+
+func TestRequest_DialWebSocket(t *testing.T) {
+	var pings int32
+	s := httptest.NewServer(newWebSocketEchoHandler(&pings))
+	defer s.Close()
+
+	jar, _ := cookiejar.New(nil)
+	u, _ := url.Parse(s.URL)
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "golang"}})
+	req := NewRequest(
+		WithCookieJar(jar),
+		WithWebSocketCompression(),
+		WithWebSocketPingInterval(20*time.Millisecond),
+	)
+	conn, err := req.DialWebSocket(context.Background(), wsURL(s.URL), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteMessage(TextMessage, []byte(strings.Repeat("hello", 100))))
+	messageType, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, TextMessage, messageType)
+	require.Equal(t, strings.Repeat("hello", 100), string(data))
+
+	require.NoError(t, conn.WriteMessage(TextMessage, []byte("cookie")))
+	_, data, err = conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, "golang", string(data))
+
+	require.NoError(t, conn.WriteJSON(map[string]string{"name": "golang"}))
+	result := map[string]string{}
+	require.NoError(t, conn.ReadJSON(&result))
+	require.Equal(t, "golang", result["name"])
+
+	// 保持读取以处理pong, 空闲期间连接不应超时
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, conn.WriteMessage(BinaryMessage, []byte{1}))
+	_, data, err = conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, []byte{1}, data)
+	require.True(t, atomic.LoadInt32(&pings) >= 2)
+	require.NoError(t, conn.Close())
+}
+
+func TestRequest_DialWebSocketUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpclient")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "ws.sock")
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	s := httptest.NewUnstartedServer(newWebSocketEchoHandler(nil))
+	s.Listener = l
+	s.Start()
+	defer s.Close()
+
+	req := NewRequest(WithUnixSocketPath(socket))
+	conn, err := req.DialWebSocket(context.Background(), "ws://docker/events", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.WriteMessage(TextMessage, []byte("unix")))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, "unix", string(data))
+}
+
+func TestRequest_DialWebSocketHandshakeError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.Error(rw, "forbidden", http.StatusForbidden)
+	}))
+	defer s.Close()
+
+	req := NewRequest()
+	_, err := req.DialWebSocket(context.Background(), wsURL(s.URL), nil)
+	var handshakeErr *WebSocketHandshakeError
+	require.True(t, errors.As(err, &handshakeErr))
+	require.Equal(t, http.StatusForbidden, handshakeErr.StatusCode)
+	require.Equal(t, "forbidden\n", handshakeErr.Body)
+}